@@ -0,0 +1,123 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// AdoptCounterVec sets the metric function pointed by field to report the counters of the vec provided,
+// which should already be registered. The labels struct of the function should have the same labels as the vec,
+// and the function should return prometheus.Counter or an interface implemented by it.
+func AdoptCounterVec(vec *prometheus.CounterVec, field interface{}) error {
+	return adopt(adoptedVec{
+		collector:  vec,
+		metricType: prometheusvanilla.CounterType,
+		curry:      func(labels prometheus.Labels) error { _, err := vec.CurryWith(labels); return err },
+		with:       func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+	}, field)
+}
+
+// AdoptGaugeVec sets the metric function pointed by field to report the gauges of the vec provided, like AdoptCounterVec does.
+func AdoptGaugeVec(vec *prometheus.GaugeVec, field interface{}) error {
+	return adopt(adoptedVec{
+		collector:  vec,
+		metricType: prometheusvanilla.GaugeType,
+		curry:      func(labels prometheus.Labels) error { _, err := vec.CurryWith(labels); return err },
+		with:       func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+	}, field)
+}
+
+// AdoptHistogramVec sets the metric function pointed by field to report the histograms of the vec provided, like AdoptCounterVec does.
+func AdoptHistogramVec(vec *prometheus.HistogramVec, field interface{}) error {
+	return adopt(adoptedVec{
+		collector:  vec,
+		metricType: prometheusvanilla.HistogramType,
+		curry:      func(labels prometheus.Labels) error { _, err := vec.CurryWith(labels); return err },
+		with:       func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+	}, field)
+}
+
+// AdoptSummaryVec sets the metric function pointed by field to report the summaries of the vec provided, like AdoptCounterVec does.
+func AdoptSummaryVec(vec *prometheus.SummaryVec, field interface{}) error {
+	return adopt(adoptedVec{
+		collector:  vec,
+		metricType: prometheusvanilla.SummaryType,
+		curry:      func(labels prometheus.Labels) error { _, err := vec.CurryWith(labels); return err },
+		with:       func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+	}, field)
+}
+
+// adoptedVec is a prometheus vec whose metrics are reported by an adopted metric function
+type adoptedVec struct {
+	collector prometheus.Collector
+	// metricType is the type of the metrics of the vec
+	metricType reflect.Type
+	// curry curries the vec with the labels provided, failing if any of them is not a label of the vec
+	curry func(prometheus.Labels) error
+	// with returns the metric of the vec for the labels provided
+	with func(prometheus.Labels) interface{}
+}
+
+// adopt sets the metric function pointed by field to report the metrics of the vec provided
+func adopt(vec adoptedVec, field interface{}) error {
+	fieldPtr := reflect.ValueOf(field)
+	if fieldPtr.Kind() != reflect.Ptr || fieldPtr.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("expected pointer to a metric function, got %s", fieldPtr.Type())
+	}
+	fieldType := fieldPtr.Elem().Type()
+	path := fieldType.String()
+
+	var labels []label
+	if fieldType.NumIn() > 1 {
+		return fmt.Errorf("field %s: expected 1 in arg, got %d", path, fieldType.NumIn())
+	} else if fieldType.NumIn() == 1 {
+		var err error
+		if labels, err = findLabels(path, fieldType.In(0)); err != nil {
+			return err
+		}
+	}
+
+	if fieldType.NumOut() != 1 {
+		return fmt.Errorf("field %s: expected 1 return arg, got %d", path, fieldType.NumOut())
+	}
+	returnArg := fieldType.Out(0)
+	if returnArg != vec.metricType && !(returnArg.Kind() == reflect.Interface && vec.metricType.Implements(returnArg)) {
+		return fmt.Errorf("field %s: vec metrics of type %s can't be returned as %s", path, vec.metricType, returnArg)
+	}
+
+	if err := checkVecLabels(vec, labels); err != nil {
+		return fmt.Errorf("field %s: labels don't match the labels of the vec: %s", path, err)
+	}
+
+	fieldPtr.Elem().Set(reflect.MakeFunc(fieldType, func(args []reflect.Value) []reflect.Value {
+		values := prometheus.Labels{}
+		if len(args) == 1 {
+			values = labelsFromValue(labels, args[0])
+		}
+		return []reflect.Value{reflect.ValueOf(vec.with(values)).Convert(returnArg)}
+	}))
+	return nil
+}
+
+// checkVecLabels checks that the labels of the vec are the ones provided without creating any metric:
+// currying the vec with them checks that all of them are labels of the vec,
+// and creating a const metric for its descriptor checks that the vec has no other labels.
+func checkVecLabels(vec adoptedVec, labels []label) error {
+	names, _ := labelNamesAndTypes(labels)
+	curried := make(prometheus.Labels, len(names))
+	for _, name := range names {
+		curried[name] = ""
+	}
+	if err := vec.curry(curried); err != nil {
+		return err
+	}
+
+	values := make([]string, len(names))
+	if _, err := prometheus.NewConstMetric(describe(vec.collector), prometheus.UntypedValue, 0, values...); err != nil {
+		return err
+	}
+	return nil
+}
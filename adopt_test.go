@@ -0,0 +1,107 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdopt(t *testing.T) {
+	type labels struct {
+		Code   int    `label:"code"`
+		Method string `label:"method"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		counterVec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "Requests"}, []string{"method", "code"})
+		gaugeVec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "in_flight", Help: "In flight"}, nil)
+		histogramVec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Name: "duration_seconds", Help: "Duration", Buckets: []float64{1}}, []string{"code", "method"})
+		summaryVec := prometheus.NewSummaryVec(prometheus.SummaryOpts{Name: "size_bytes", Help: "Size"}, []string{"code", "method"})
+		registry.MustRegister(counterVec, gaugeVec, histogramVec, summaryVec)
+
+		var metrics struct {
+			Requests func(labels) prometheus.Counter
+			InFlight func() prometheus.Gauge
+			Duration func(labels) prometheus.Observer
+			Size     func(labels) prometheus.Summary
+		}
+		require.NoError(t, AdoptCounterVec(counterVec, &metrics.Requests))
+		require.NoError(t, AdoptGaugeVec(gaugeVec, &metrics.InFlight))
+		require.NoError(t, AdoptHistogramVec(histogramVec, &metrics.Duration))
+		require.NoError(t, AdoptSummaryVec(summaryVec, &metrics.Size))
+
+		metrics.Requests(labels{Code: 200, Method: "GET"}).Inc()
+		counterVec.WithLabelValues("GET", "200").Inc()
+		metrics.InFlight().Set(3)
+		metrics.Duration(labels{Code: 200, Method: "GET"}).Observe(0.5)
+
+		expected := `
+# HELP duration_seconds Duration
+# TYPE duration_seconds histogram
+duration_seconds_bucket{code="200",method="GET",le="1"} 1
+duration_seconds_bucket{code="200",method="GET",le="+Inf"} 1
+duration_seconds_sum{code="200",method="GET"} 0.5
+duration_seconds_count{code="200",method="GET"} 1
+# HELP in_flight In flight
+# TYPE in_flight gauge
+in_flight 3
+# HELP requests_total Requests
+# TYPE requests_total counter
+requests_total{code="200",method="GET"} 2
+`
+		err := testutil.GatherAndCompare(registry, strings.NewReader(expected), "duration_seconds", "in_flight", "requests_total")
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		newVec := func(labelNames ...string) *prometheus.CounterVec {
+			return prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "Requests"}, labelNames)
+		}
+
+		for _, tc := range []struct {
+			desc  string
+			vec   *prometheus.CounterVec
+			field interface{}
+		}{
+			{
+				desc:  "not a pointer",
+				vec:   newVec("code", "method"),
+				field: func(labels) prometheus.Counter { return nil },
+			},
+			{
+				desc:  "missing label in the vec",
+				vec:   newVec("code"),
+				field: new(func(labels) prometheus.Counter),
+			},
+			{
+				desc:  "missing label in the labels struct",
+				vec:   newVec("code", "method", "path"),
+				field: new(func(labels) prometheus.Counter),
+			},
+			{
+				desc:  "different labels",
+				vec:   newVec("code", "path"),
+				field: new(func(labels) prometheus.Counter),
+			},
+			{
+				desc:  "wrong type",
+				vec:   newVec("code", "method"),
+				field: new(func(labels) prometheus.Gauge),
+			},
+			{
+				desc:  "wrong labels",
+				vec:   newVec("code", "method"),
+				field: new(func(string) prometheus.Counter),
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				assert.Error(t, AdoptCounterVec(tc.vec, tc.field))
+			})
+		}
+	})
+}
@@ -0,0 +1,229 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// OverflowLabelValue is the value of all the labels of the overflow series of a metric,
+// which reports the values of the label combinations exceeding its series budget with the OverflowSeries policy
+const OverflowLabelValue = "overflow"
+
+// budgetRefreshInterval is the minimum interval between the checks of the series that still exist
+// once a metric exceeds its series budget, since series can be deleted by companions, the ttl tag or external code
+const budgetRefreshInterval = time.Second
+
+// BudgetPolicy is what a metric func does when it's called with a new label combination that exceeds a series budget
+type BudgetPolicy int
+
+const (
+	// DropSeries returns a metric whose values aren't collected, shared by all the dropped label combinations, or the overflow series if the builder can't provide one
+	DropSeries BudgetPolicy = iota
+	// OverflowSeries returns the overflow series of the metric, whose labels have the OverflowLabelValue
+	OverflowSeries
+	// WarnSeries returns the metric for the label combination anyway, only calling the budget hook
+	WarnSeries
+)
+
+// String implements fmt.Stringer
+func (p BudgetPolicy) String() string {
+	switch p {
+	case DropSeries:
+		return "drop"
+	case OverflowSeries:
+		return "overflow"
+	case WarnSeries:
+		return "warn"
+	default:
+		return fmt.Sprintf("BudgetPolicy(%d)", int(p))
+	}
+}
+
+// BudgetExceeded describes a new label combination that exceeds a series budget, it's provided to the budget hook
+type BudgetExceeded struct {
+	// FieldPath is the path of the metric func field, like Requests.Total
+	FieldPath string
+	// Metric is the name of the metric, including its namespace
+	Metric string
+	// Labels is the labels struct the metric func was called with, or nil if it has no labels
+	Labels interface{}
+	// Values are the values of all the labels of the label combination, including the ones bound by the groups
+	Values prometheus.Labels
+	// Budget is the series budget exceeded
+	Budget int
+	// Global tells whether the budget exceeded is the global one, or the one of the metric otherwise
+	Global bool
+	// Policy is the policy applied
+	Policy BudgetPolicy
+}
+
+// Error implements error
+func (e BudgetExceeded) Error() string {
+	budget := "series budget"
+	if e.Global {
+		budget = "global series budget"
+	}
+	return fmt.Sprintf("field %s: metric %q exceeds its %s of %d with labels %v, policy %s applied", e.FieldPath, e.Metric, budget, e.Budget, e.Values, e.Policy)
+}
+
+// budgetFromTag returns the series budget of the budget tag of the metric in the path provided, or the one provided if it's not defined
+func budgetFromTag(path string, tag reflect.StructTag, budget int) (int, error) {
+	budgetString, ok := tag.Lookup("budget")
+	if !ok {
+		return budget, nil
+	}
+
+	budget, err := strconv.Atoi(budgetString)
+	if err != nil || budget <= 0 {
+		return 0, fmt.Errorf("field %s: budget tag should be a positive integer, got %q", path, budgetString)
+	}
+	return budget, nil
+}
+
+// budgetAction is what a metric func does with a label combination
+type budgetAction int
+
+const (
+	admitSeries budgetAction = iota
+	dropSeries
+	overflowSeries
+)
+
+// seriesBudget enforces the series budget of a metric and the global one of its Initializer,
+// it's nil if neither of them is set
+type seriesBudget struct {
+	in         *initializer
+	path       string
+	metric     string
+	budget     int
+	collectors func() []prometheus.Collector
+
+	mu          sync.Mutex
+	admitted    map[string]struct{}
+	lastRefresh time.Time
+}
+
+// newSeriesBudget returns the series budget of the metric provided, or nil if it has no budget
+func (in *initializer) newSeriesBudget(path, name string, tag reflect.StructTag, metric builtMetric) (*seriesBudget, error) {
+	budget, err := budgetFromTag(path, tag, in.options.MetricSeriesBudget)
+	if err != nil {
+		return nil, err
+	}
+	if budget <= 0 && in.options.GlobalSeriesBudget <= 0 {
+		return nil, nil
+	}
+	return &seriesBudget{
+		in:         in,
+		path:       path,
+		metric:     name,
+		budget:     budget,
+		collectors: metric.collectors,
+		admitted:   make(map[string]struct{}),
+	}, nil
+}
+
+// admit tells what to do with the label combination provided, which was built from the labels struct provided, if any
+func (b *seriesBudget) admit(values prometheus.Labels, labels []reflect.Value) budgetAction {
+	exceeded, budget, global := b.check(seriesKey(values))
+	if !exceeded {
+		return admitSeries
+	}
+
+	e := BudgetExceeded{
+		FieldPath: b.path,
+		Metric:    b.metric,
+		Values:    values,
+		Budget:    budget,
+		Global:    global,
+		Policy:    b.in.options.BudgetPolicy,
+	}
+	if len(labels) == 1 {
+		e.Labels = labels[0].Interface()
+	}
+	if b.in.self != nil {
+		b.in.self.labelOverflow.WithLabelValues(b.metric).Inc()
+	}
+	if b.in.options.BudgetHook != nil {
+		b.in.options.BudgetHook(e)
+	} else if e.Policy == WarnSeries {
+		b.in.handleError(b.metric, budgetErrorKind, e)
+	}
+
+	switch e.Policy {
+	case WarnSeries:
+		return admitSeries
+	case OverflowSeries:
+		return overflowSeries
+	default:
+		return dropSeries
+	}
+}
+
+// check admits the label combination with the key provided, unless it's new and exceeds a budget,
+// telling which budget it exceeds and whether it's the global one. Label combinations exceeding a budget
+// are admitted anyway with the WarnSeries policy, so they're reported only once.
+func (b *seriesBudget) check(key string) (exceeded bool, budget int, global bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.admitted[key]; ok {
+		return false, 0, false
+	}
+	exceeded, budget, global = b.exceeded()
+	if exceeded && now().Sub(b.lastRefresh) >= budgetRefreshInterval {
+		b.refresh()
+		exceeded, budget, global = b.exceeded()
+	}
+	if !exceeded || b.in.options.BudgetPolicy == WarnSeries {
+		b.add(key)
+	}
+	return exceeded, budget, global
+}
+
+// exceeded tells whether admitting a new label combination exceeds a budget, which one, and whether it's the global one
+func (b *seriesBudget) exceeded() (exceeded bool, budget int, global bool) {
+	if b.budget > 0 && len(b.admitted) >= b.budget {
+		return true, b.budget, false
+	}
+	if globalBudget := b.in.options.GlobalSeriesBudget; globalBudget > 0 && atomic.LoadInt64(&b.in.admittedSeries) >= int64(globalBudget) {
+		return true, globalBudget, true
+	}
+	return false, 0, false
+}
+
+// add admits the label combination with the key provided
+func (b *seriesBudget) add(key string) {
+	b.admitted[key] = struct{}{}
+	atomic.AddInt64(&b.in.admittedSeries, 1)
+}
+
+// refresh forgets the admitted label combinations whose series don't exist anymore
+func (b *seriesBudget) refresh() {
+	b.lastRefresh = now()
+
+	existing := make(map[string]bool)
+	for _, series := range collectSeries(b.collectors()) {
+		existing[seriesKey(series.Labels)] = true
+	}
+	for key := range b.admitted {
+		if !existing[key] {
+			delete(b.admitted, key)
+			atomic.AddInt64(&b.in.admittedSeries, -1)
+		}
+	}
+}
+
+// overflowValues returns the label values of the overflow series for the label values provided
+func overflowValues(values prometheus.Labels) prometheus.Labels {
+	overflow := make(prometheus.Labels, len(values))
+	for name := range values {
+		overflow[name] = OverflowLabelValue
+	}
+	return overflow
+}
@@ -0,0 +1,228 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_SeriesBudget(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(registry, opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		return initializer
+	}
+
+	type labels struct {
+		Tenant string `label:"tenant"`
+	}
+	type metrics struct {
+		Requests       func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+		DeleteRequests func(labels) bool               `metric:"Requests"`
+	}
+	report := func(m metrics, tenants ...string) {
+		for _, tenant := range tenants {
+			m.Requests(labels{Tenant: tenant}).Inc()
+		}
+	}
+
+	t.Run("drop", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry, WithSeriesBudget(2)).Init(&m, "test"))
+		report(m, "a", "b", "c", "a")
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{tenant="a"} 2
+test_requests_total{tenant="b"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("overflow", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry, WithSeriesBudget(1), WithBudgetPolicy(OverflowSeries)).Init(&m, "test"))
+		report(m, "a", "b", "c")
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{tenant="a"} 1
+test_requests_total{tenant="overflow"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("dropped label combinations share a series", func(t *testing.T) {
+		noOpVec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "requests_total", Help: "Requests"}, []string{"tenant"})
+		initializer := NewInitializer(prometheus.NewRegistry(), WithSeriesBudget(1))
+		initializer.MustAddBuilderV2(prometheusvanilla.CounterType, func(ctx BuildContext) (BuildResult, error) {
+			vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: ctx.Name, Help: ctx.Help, Namespace: ctx.Namespace}, ctx.LabelNames)
+			return BuildResult{
+				Factory:   func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+				NoOp:      func(labels prometheus.Labels) interface{} { return noOpVec.With(labels) },
+				Collector: vec,
+			}, nil
+		})
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		report(m, "a", "b", "c", "d", "e")
+
+		assert.Equal(t, 1, testutil.CollectAndCount(noOpVec))
+	})
+
+	t.Run("drop without no-op metrics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry, WithSeriesBudget(1))
+		initializer.MustAddBuilderV2(prometheusvanilla.CounterType, func(ctx BuildContext) (BuildResult, error) {
+			vec := prometheus.NewCounterVec(prometheus.CounterOpts{Name: ctx.Name, Help: ctx.Help, Namespace: ctx.Namespace}, ctx.LabelNames)
+			return BuildResult{
+				Factory:   func(labels prometheus.Labels) interface{} { return vec.With(labels) },
+				Collector: vec,
+			}, nil
+		})
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		report(m, "a", "b")
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{tenant="a"} 1
+test_requests_total{tenant="overflow"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("warn", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var exceeded []BudgetExceeded
+		initializer := newInitializer(registry,
+			WithSeriesBudget(1),
+			WithBudgetPolicy(WarnSeries),
+			WithBudgetHook(func(e BudgetExceeded) { exceeded = append(exceeded, e) }),
+		)
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		report(m, "a", "b", "b")
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{tenant="a"} 1
+test_requests_total{tenant="b"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+		assert.Equal(t, []BudgetExceeded{{
+			FieldPath: "Requests",
+			Metric:    "test_requests_total",
+			Labels:    labels{Tenant: "b"},
+			Values:    prometheus.Labels{"tenant": "b"},
+			Budget:    1,
+			Policy:    WarnSeries,
+		}}, exceeded)
+	})
+
+	t.Run("global budget", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var exceeded []BudgetExceeded
+		initializer := newInitializer(registry, WithGlobalSeriesBudget(3), WithBudgetHook(func(e BudgetExceeded) { exceeded = append(exceeded, e) }))
+
+		var m struct {
+			Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			Errors   func(labels) prometheus.Counter `name:"errors_total" help:"Errors"`
+		}
+		require.NoError(t, initializer.Init(&m, "test"))
+		m.Requests(labels{Tenant: "a"}).Inc()
+		m.Requests(labels{Tenant: "b"}).Inc()
+		m.Errors(labels{Tenant: "a"}).Inc()
+		m.Errors(labels{Tenant: "b"}).Inc()
+
+		require.Len(t, exceeded, 1)
+		assert.True(t, exceeded[0].Global)
+		assert.Equal(t, "test_errors_total", exceeded[0].Metric)
+		assert.Equal(t, 3, countGathered(t, registry, ""))
+	})
+
+	t.Run("budget tag", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m struct {
+			Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests" budget:"1"`
+			Errors   func(labels) prometheus.Counter `name:"errors_total" help:"Errors"`
+		}
+		require.NoError(t, newInitializer(registry, WithSeriesBudget(2)).Init(&m, "test"))
+		for _, tenant := range []string{"a", "b"} {
+			m.Requests(labels{Tenant: tenant}).Inc()
+			m.Errors(labels{Tenant: tenant}).Inc()
+		}
+		assert.Equal(t, 1, countGathered(t, registry, "test_requests_total"))
+		assert.Equal(t, 2, countGathered(t, registry, "test_errors_total"))
+	})
+
+	t.Run("deleted series free the budget", func(t *testing.T) {
+		clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+		defer func(original func() time.Time) { now = original }(now)
+		now = func() time.Time { return clock }
+
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry, WithSeriesBudget(1)).Init(&m, "test"))
+		report(m, "a")
+		assert.True(t, m.DeleteRequests(labels{Tenant: "a"}))
+		report(m, "b")
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{tenant="b"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("self metrics", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry, WithSeriesBudget(1), WithSelfMetrics()).Init(&m, "test"))
+		report(m, "a", "b", "c")
+
+		expected := `
+# HELP gotoprom_label_overflow_total Calls to the metric funcs initialized by gotoprom with new label combinations exceeding a series budget
+# TYPE gotoprom_label_overflow_total counter
+gotoprom_label_overflow_total{metric="test_requests_total"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "gotoprom_label_overflow_total"))
+	})
+
+	t.Run("invalid budget tag", func(t *testing.T) {
+		var m struct {
+			Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests" budget:"lots"`
+		}
+		assert.Error(t, newInitializer(prometheus.NewRegistry()).Init(&m, "test"))
+	})
+}
+
+// countGathered returns the number of series gathered from the registry provided with the name provided, or all of them if it's empty
+func countGathered(t *testing.T, registry *prometheus.Registry, name string) int {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+
+	count := 0
+	for _, family := range families {
+		if name == "" || family.GetName() == name {
+			count += len(family.GetMetric())
+		}
+	}
+	return count
+}
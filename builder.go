@@ -0,0 +1,107 @@
+package gotoprom
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// BuilderV2 is a function that builds a metric from the BuildContext provided
+// The Initializer registers the collector it returns, so the BuilderV2 doesn't need to register it.
+type BuilderV2 func(BuildContext) (BuildResult, error)
+
+// BuildContext holds everything the Initializer knows about the metric being built
+type BuildContext struct {
+	// Name is the name of the metric, without the namespace
+	Name string
+	// Help is the help of the metric
+	Help string
+	// Namespace is the namespace of the metric, which is the namespace provided to Init
+	// and the namespaces of the nested groups joined by underscores
+	Namespace string
+
+	// FieldPath is the path of the field declaring the metric, like Requests.Total
+	FieldPath string
+	// LabelNames are the names of the variable labels of the metric, in a guaranteed order: first the labels bound
+	// by the groups, from the outermost one, and then the labels of the metric, each in the order their fields are declared
+	LabelNames []string
+	// LabelTypes are the types of the label fields, in the same order as LabelNames
+	LabelTypes []reflect.Type
+	// ConstLabels are the labels the Initializer adds to all the metrics when registering them,
+	// builders shouldn't add them to their collectors
+	ConstLabels prometheus.Labels
+	// Tag is the tag of the field declaring the metric
+	Tag reflect.StructTag
+
+	// Registerer is the prometheus.Registerer the collector will be registered in,
+	// the first one if the registry tag selects several ones
+	Registerer prometheus.Registerer
+	// Options are the Options of the Initializer
+	Options Options
+}
+
+// BuildResult is the metric built by a BuilderV2
+type BuildResult struct {
+	// Factory creates the metric reporter for given label values
+	// Note that the values returned by the Factory should be (in Java words):
+	// interface{} implements <typ>
+	Factory func(prometheus.Labels) interface{}
+	// NoOp creates metric reporters like Factory does, but their values are not collected
+	// It can be nil if the builder can't provide them
+	NoOp func(prometheus.Labels) interface{}
+	// Collector is the prometheus.Collector that will be registered
+	Collector prometheus.Collector
+	// Desc describes the metric, if it's nil then the first prometheus.Desc
+	// described by the Collector will be used
+	Desc *prometheus.Desc
+	// TagKeys are the tag keys accepted by the builder, besides the ones accepted in all the metric fields.
+	// If it's nil the builder doesn't declare them, and the tag keys of the metric aren't checked.
+	// It can be set even if the builder fails, so misspelled tag keys are reported along with the failure.
+	TagKeys []string
+}
+
+// adaptBuilder adapts a Builder to a BuilderV2
+// The NoOp of the adapted builder is a second metric built with the same arguments that is never registered.
+func adaptBuilder(builder Builder) BuilderV2 {
+	return func(ctx BuildContext) (BuildResult, error) {
+		factory, collector, err := builder(ctx.Name, ctx.Help, ctx.Namespace, ctx.LabelNames, ctx.Tag)
+		if err != nil {
+			return BuildResult{}, err
+		}
+
+		var once sync.Once
+		var noOp func(prometheus.Labels) interface{}
+		return BuildResult{
+			Factory: factory,
+			NoOp: func(labels prometheus.Labels) interface{} {
+				once.Do(func() {
+					var err error
+					noOp, _, err = builder(ctx.Name, ctx.Help, ctx.Namespace, ctx.LabelNames, ctx.Tag)
+					if err != nil {
+						// Should not happen since the same arguments have already built a metric
+						noOp = factory
+					}
+				})
+				return noOp(labels)
+			},
+			Collector: collector,
+			Desc:      describe(collector),
+		}, nil
+	}
+}
+
+// describe returns the first prometheus.Desc described by the collector provided
+func describe(collector prometheus.Collector) *prometheus.Desc {
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		collector.Describe(ch)
+		close(ch)
+	}()
+
+	desc := <-ch
+	for range ch {
+		// drain the channel so the goroutine can finish
+	}
+	return desc
+}
@@ -0,0 +1,129 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_AddBuilderV2(t *testing.T) {
+	type labels struct {
+		Name  string `label:"name"`
+		Count int    `label:"count"`
+	}
+
+	registry := prometheus.NewRegistry()
+	initializer := NewInitializer(registry, WithConstLabels(prometheus.Labels{"env": "test"}))
+
+	var ctx BuildContext
+	err := initializer.AddBuilderV2(prometheusvanilla.GaugeType, func(c BuildContext) (BuildResult, error) {
+		ctx = c
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Namespace: c.Namespace, Name: c.Name, Help: c.Help})
+		return BuildResult{
+			Factory:   func(prometheus.Labels) interface{} { return gauge },
+			Collector: gauge,
+		}, nil
+	})
+	require.NoError(t, err)
+
+	err = initializer.AddBuilderV2(prometheusvanilla.GaugeType, nil)
+	assert.Error(t, err, "same builder twice fails")
+
+	var metrics struct {
+		Group struct {
+			Metric func(labels) prometheus.Gauge `name:"metric" help:"Some help" custom:"tag"`
+		} `namespace:"group"`
+	}
+	err = initializer.Init(&metrics, "test")
+	require.NoError(t, err)
+
+	assert.Equal(t, "metric", ctx.Name)
+	assert.Equal(t, "Some help", ctx.Help)
+	assert.Equal(t, "test_group", ctx.Namespace)
+	assert.Equal(t, "Group.Metric", ctx.FieldPath)
+	assert.Equal(t, []string{"name", "count"}, ctx.LabelNames)
+	assert.Equal(t, []reflect.Type{reflect.TypeOf(""), reflect.TypeOf(0)}, ctx.LabelTypes)
+	assert.Equal(t, prometheus.Labels{"env": "test"}, ctx.ConstLabels)
+	assert.Equal(t, prometheus.Labels{"env": "test"}, ctx.Options.ConstLabels)
+	assert.Equal(t, "tag", ctx.Tag.Get("custom"))
+	assert.NotNil(t, ctx.Registerer)
+
+	metrics.Group.Metric(labels{}).Set(42)
+	expected := `
+# HELP test_group_metric Some help
+# TYPE test_group_metric gauge
+test_group_metric{env="test"} 42
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+	assert.NoError(t, err)
+}
+
+func TestAdaptBuilder(t *testing.T) {
+	builder := adaptBuilder(prometheusvanilla.BuildCounter)
+
+	t.Run("happy case", func(t *testing.T) {
+		result, err := builder(BuildContext{Name: "counter", Help: "Some help", Namespace: "test", LabelNames: []string{"label"}})
+		require.NoError(t, err)
+
+		registry := prometheus.NewRegistry()
+		require.NoError(t, registry.Register(result.Collector))
+
+		result.Factory(prometheus.Labels{"label": "reported"}).(prometheus.Counter).Inc()
+		result.NoOp(prometheus.Labels{"label": "not_reported"}).(prometheus.Counter).Inc()
+
+		expected := `
+# HELP test_counter Some help
+# TYPE test_counter counter
+test_counter{label="reported"} 1
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+		assert.Equal(t, prometheus.NewDesc("test_counter", "Some help", []string{"label"}, nil).String(), result.Desc.String())
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		_, err := adaptBuilder(prometheusvanilla.BuildHistogram)(BuildContext{Name: "histogram", Help: "Some help"})
+		assert.Error(t, err)
+	})
+}
+
+func TestInitializer_Init_LabelOrder(t *testing.T) {
+	type baseLabels struct {
+		Region string `label:"region"`
+		Zone   string `label:"zone"`
+	}
+	type requestLabels struct {
+		Method string `label:"method"`
+		baseLabels
+		Code int `label:"code"`
+	}
+	type serviceMetrics struct {
+		With func(struct {
+			Service string `label:"service"`
+		}) serviceMetrics
+		Requests func(requestLabels) prometheus.Counter `name:"requests_total" help:"Requests"`
+	}
+
+	var labelNames []string
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilderV2(prometheusvanilla.CounterType, func(ctx BuildContext) (BuildResult, error) {
+		labelNames = ctx.LabelNames
+		return adaptBuilder(prometheusvanilla.BuildCounter)(ctx)
+	})
+
+	for i := 0; i < 10; i++ {
+		var metrics struct {
+			Service serviceMetrics `namespace:"service"`
+		}
+		err := initializer.Clone().Init(&metrics, fmt.Sprintf("test%d", i))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"service", "method", "region", "zone", "code"}, labelNames, "group labels first, then metric labels in declaration order")
+	}
+}
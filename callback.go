@@ -0,0 +1,197 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// GaugeFunc is a gauge whose value is provided by the callback when the metrics are collected.
+// Fields of this type are registered by Init and the callback can be assigned to them afterwards,
+// a nil callback reports no value.
+type GaugeFunc func() float64
+
+// CounterFunc is a counter whose value is provided by the callback when the metrics are collected.
+// Fields of this type are registered by Init and the callback can be assigned to them afterwards,
+// a nil callback reports no value.
+// The value returned by the callback should never decrease.
+type CounterFunc func() float64
+
+var (
+	gaugeFuncType   = reflect.TypeOf(GaugeFunc(nil))
+	counterFuncType = reflect.TypeOf(CounterFunc(nil))
+)
+
+// isCallback tells whether the type provided is a GaugeFunc, a CounterFunc
+// or a labeled callback: a func with no arguments returning a map from a labels struct to a float
+func isCallback(typ reflect.Type) bool {
+	if typ == gaugeFuncType || typ == counterFuncType {
+		return true
+	}
+	return typ.Kind() == reflect.Func &&
+		typ.NumIn() == 0 &&
+		typ.NumOut() == 1 &&
+		typ.Out(0).Kind() == reflect.Map
+}
+
+// initCallback registers a collector that calls the callback stored in the field provided when collecting.
+// Labeled callbacks have to define whether they're a gauge or a counter using the type tag.
+// The path of the scope provided should already include the field.
+func (in *initializer) initCallback(field reflect.Value, structField reflect.StructField, s scope) error {
+	namespace := strings.Join(s.namespaces, "_")
+	fieldType := field.Type()
+	path := s.fieldPath()
+
+	if !field.CanSet() {
+		return fmt.Errorf("field %s needs be exported", path)
+	}
+
+	tag := structField.Tag
+	name, ok := tag.Lookup("name")
+	if !ok {
+		return ErrMissingTag{Field: path, Tag: "name"}
+	}
+	help, ok := tag.Lookup("help")
+	if !ok {
+		return ErrMissingTag{Field: path, Tag: "help"}
+	}
+
+	if err := in.checkTagKeys(path, tag, callbackTagKeys); err != nil {
+		return err
+	}
+
+	var valueType prometheus.ValueType
+	switch fieldType {
+	case gaugeFuncType:
+		valueType = prometheus.GaugeValue
+	case counterFuncType:
+		valueType = prometheus.CounterValue
+	default:
+		typ, ok := tag.Lookup("type")
+		if !ok {
+			return ErrMissingTag{Field: path, Tag: "type"}
+		}
+		switch typ {
+		case "gauge":
+			valueType = prometheus.GaugeValue
+		case "counter":
+			valueType = prometheus.CounterValue
+		default:
+			return fmt.Errorf("field %s: type tag should be gauge or counter, got %q", path, typ)
+		}
+	}
+
+	var labels []label
+	if returnArg := fieldType.Out(0); returnArg.Kind() == reflect.Map {
+		if k := returnArg.Elem().Kind(); k != reflect.Float32 && k != reflect.Float64 {
+			return fmt.Errorf("field %s: expected callback to return map values of float type, got %s", path, k)
+		}
+		var err error
+		if labels, err = findLabels(path, returnArg.Key()); err != nil {
+			return err
+		}
+		if err := in.checkLabelTagKeys(path, returnArg.Key()); err != nil {
+			return err
+		}
+	}
+	labelNames, _ := labelNamesAndTypes(labels)
+	fqName := prometheus.BuildFQName(namespace, "", name)
+
+	if in.options.StrictNaming {
+		kind := gaugeKind
+		if valueType == prometheus.CounterValue {
+			kind = counterKind
+		}
+		if err := checkNaming(path, fqName, kind, in.withConstLabelNames(labelNames), tag.Get("unit")); err != nil {
+			return err
+		}
+	}
+
+	collector := callbackCollector{
+		desc:       prometheus.NewDesc(fqName, help, labelNames, nil),
+		valueType:  valueType,
+		callback:   field,
+		labels:     labels,
+		labelNames: labelNames,
+		collected:  new(int64),
+	}
+
+	s, err := s.withRegistryTag(tag)
+	if err != nil {
+		return err
+	}
+	registerers, err := in.registerersFor(s)
+	if err != nil {
+		return err
+	}
+	for _, registerer := range registerers {
+		if err := registerer.Register(collector); err != nil && !isAlreadyRegistered(err, collector) {
+			return fmt.Errorf("field %s: register metric %q: %s", path, name, err)
+		}
+	}
+
+	in.declare(declaredMetric{
+		fieldPath:  path,
+		name:       fqName,
+		typ:        fieldType.String(),
+		help:       help,
+		labelNames: labelNames,
+		registries: s.registries,
+		collectors: func() []prometheus.Collector { return []prometheus.Collector{collector} },
+		series:     func() (int, bool) { return int(atomic.LoadInt64(collector.collected)), true },
+	})
+	return nil
+}
+
+// callbackCollector is a prometheus.Collector that reports the values returned by a callback
+type callbackCollector struct {
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+
+	// callback is the addressable value of the field holding the callback,
+	// so callbacks assigned after the registration are also called
+	callback   reflect.Value
+	labels     []label
+	labelNames []string
+	// collected is the number of series reported by the last collection, so they can be counted without calling the callback
+	collected *int64
+}
+
+// Describe implements prometheus.Collector
+func (c callbackCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c callbackCollector) Collect(ch chan<- prometheus.Metric) {
+	if c.callback.IsNil() {
+		atomic.StoreInt64(c.collected, 0)
+		return
+	}
+
+	out := c.callback.Call(nil)[0]
+	if out.Kind() != reflect.Map {
+		atomic.StoreInt64(c.collected, 1)
+		ch <- prometheus.MustNewConstMetric(c.desc, c.valueType, out.Float())
+		return
+	}
+	atomic.StoreInt64(c.collected, int64(out.Len()))
+
+	iter := out.MapRange()
+	for iter.Next() {
+		labels := labelsFromValue(c.labels, iter.Key())
+		labelValues := make([]string, len(c.labelNames))
+		for i, name := range c.labelNames {
+			labelValues[i] = labels[name]
+		}
+
+		metric, err := prometheus.NewConstMetric(c.desc, c.valueType, iter.Value().Float(), labelValues...)
+		if err != nil {
+			metric = prometheus.NewInvalidMetric(c.desc, err)
+		}
+		ch <- metric
+	}
+}
@@ -0,0 +1,122 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitializer_Init_Callbacks(t *testing.T) {
+	type queueLabels struct {
+		Queue string `label:"queue"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry)
+
+		var metrics struct {
+			QueueDepth    GaugeFunc                      `name:"queue_depth" help:"Depth of the queue"`
+			ProcessedJobs CounterFunc                    `name:"processed_jobs_total" help:"Jobs processed"`
+			QueueDepths   func() map[queueLabels]float64 `name:"queue_depths" help:"Depth of each queue" type:"gauge"`
+			Unassigned    GaugeFunc                      `name:"unassigned" help:"Callback never assigned"`
+			Retries       func() map[queueLabels]float64 `name:"retries_total" help:"Retries of each queue" type:"counter"`
+			Nested        struct {
+				Depth GaugeFunc `name:"depth" help:"Nested depth"`
+			} `namespace:"nested"`
+		}
+		err := initializer.Init(&metrics, "test")
+		assert.NoError(t, err)
+
+		metrics.QueueDepth = func() float64 { return 42 }
+		metrics.ProcessedJobs = func() float64 { return 288 }
+		metrics.QueueDepths = func() map[queueLabels]float64 {
+			return map[queueLabels]float64{{Queue: "high"}: 1, {Queue: "low"}: 2}
+		}
+		metrics.Retries = func() map[queueLabels]float64 {
+			return map[queueLabels]float64{{Queue: "high"}: 3}
+		}
+		metrics.Nested.Depth = func() float64 { return 7 }
+
+		expected := `
+# HELP test_nested_depth Nested depth
+# TYPE test_nested_depth gauge
+test_nested_depth 7
+# HELP test_processed_jobs_total Jobs processed
+# TYPE test_processed_jobs_total counter
+test_processed_jobs_total 288
+# HELP test_queue_depth Depth of the queue
+# TYPE test_queue_depth gauge
+test_queue_depth 42
+# HELP test_queue_depths Depth of each queue
+# TYPE test_queue_depths gauge
+test_queue_depths{queue="high"} 1
+test_queue_depths{queue="low"} 2
+# HELP test_retries_total Retries of each queue
+# TYPE test_retries_total counter
+test_retries_total{queue="high"} 3
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "missing name",
+				metrics: &struct {
+					Foo GaugeFunc `help:"missing name tag"`
+				}{},
+			},
+			{
+				desc: "missing help",
+				metrics: &struct {
+					Foo CounterFunc `name:"nohelp"`
+				}{},
+			},
+			{
+				desc: "labeled callback without type",
+				metrics: &struct {
+					Foo func() map[queueLabels]float64 `name:"notype" help:"type is missing"`
+				}{},
+			},
+			{
+				desc: "labeled callback with unknown type",
+				metrics: &struct {
+					Foo func() map[queueLabels]float64 `name:"wrongtype" help:"type is wrong" type:"histogram"`
+				}{},
+			},
+			{
+				desc: "labeled callback returning strings",
+				metrics: &struct {
+					Foo func() map[queueLabels]string `name:"strings" help:"values are strings" type:"gauge"`
+				}{},
+			},
+			{
+				desc: "labeled callback with keys that are not labels",
+				metrics: &struct {
+					Foo func() map[string]float64 `name:"stringkeys" help:"keys are strings" type:"gauge"`
+				}{},
+			},
+			{
+				desc: "registration fails",
+				metrics: &struct {
+					Foo GaugeFunc `name:"metric" help:"first gauge"`
+					Bar GaugeFunc `name:"metric" help:"name duplicates the previous one"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				initializer := NewInitializer(prometheus.NewRegistry())
+				err := initializer.Init(tc.metrics, "namespace")
+				assert.Error(t, err)
+			})
+		}
+	})
+}
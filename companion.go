@@ -0,0 +1,184 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// companionTagKeys are the tag keys accepted in companion fields
+var companionTagKeys = []string{"metric"}
+
+// deleter is implemented by the collectors that can delete the series with some label values, like the vecs
+type deleter interface {
+	Delete(prometheus.Labels) bool
+}
+
+// resetter is implemented by the collectors that can delete all their series, like the vecs
+type resetter interface {
+	Reset()
+}
+
+// isCompanion tells whether the struct field provided is a companion of a metric:
+// a func field with the metric tag naming a metric func field of the same group, like `metric:"Requests"`,
+// that deletes series of that metric, like `DeleteRequests func(requestLabels) bool`,
+// or resets it, like `ResetRequests func()`
+func isCompanion(structField reflect.StructField) bool {
+	_, ok := structField.Tag.Lookup("metric")
+	return ok && structField.Type.Kind() == reflect.Func
+}
+
+// initCompanion checks the companion field provided, declared in the scope provided, whose path should already include the field,
+// and returns the filler that sets it. The metrics are the ones built for the metric func fields of the group type provided.
+//
+// Companions with no out args reset the metric, deleting only the series with the labels bound by the groups if there are any,
+// and companions returning a bool delete the series with the labels they receive,
+// plus the labels bound by the groups, telling whether they were deleted.
+func (in *initializer) initCompanion(field reflect.Value, structField reflect.StructField, s scope, groupType reflect.Type, metrics map[string]builtMetric) (filler, error) {
+	path := s.fieldPath()
+	if !field.CanSet() {
+		return nil, fmt.Errorf("field %s needs be exported", path)
+	}
+	if err := in.checkTagKeys(path, structField.Tag, companionTagKeys); err != nil {
+		return nil, err
+	}
+
+	target := structField.Tag.Get("metric")
+	targetField, ok := groupType.FieldByName(target)
+	if !ok || targetField.Type.Kind() != reflect.Func || isCallback(targetField.Type) || isCompanion(targetField) {
+		return nil, fmt.Errorf("field %s: metric tag should name a metric func field of the same group, got %q", path, target)
+	}
+	metric, ok := metrics[target]
+	if !ok {
+		// The metric failed to initialize, its error is already reported
+		return nil, nil
+	}
+
+	name := prometheus.BuildFQName(strings.Join(s.namespaces, "_"), "", targetField.Tag.Get("name"))
+	companionType := structField.Type
+	switch {
+	case companionType.NumIn() == 0 && companionType.NumOut() == 0:
+		labeled := s
+		if targetField.Type.NumIn() == 1 {
+			labels, err := findLabels(path, targetField.Type.In(0))
+			if err != nil {
+				return nil, err
+			}
+			if labeled, err = s.withLabels(labels); err != nil {
+				return nil, err
+			}
+		}
+		return in.resetCompanion(path, target, name, metric, labeled.labelNames, len(s.labelNames) > 0)
+	case companionType.NumOut() == 1 && companionType.Out(0).Kind() == reflect.Bool:
+		return in.deleteCompanion(path, target, name, metric, companionType, targetField.Type)
+	default:
+		return nil, fmt.Errorf("field %s: expected companion of metric %s to be a func() resetting it or a func returning a bool deleting its series, got %s", path, target, companionType)
+	}
+}
+
+// resetCompanion returns the filler of a companion resetting the metric provided, named like the name provided,
+// whose series have the label names provided.
+// If the companion is declared in a group binding labels, it only deletes the series with the labels bound,
+// so the series of the other label values bound by the groups are kept.
+func (in *initializer) resetCompanion(path, target, name string, metric builtMetric, labelNames []string, groupLabeled bool) (filler, error) {
+	if groupLabeled {
+		if err := checkCollectors(path, target, metric, reflect.TypeOf((*deleter)(nil)).Elem(), "delete series"); err != nil {
+			return nil, err
+		}
+	} else if err := checkCollectors(path, target, metric, reflect.TypeOf((*resetter)(nil)).Elem(), "be reset"); err != nil {
+		return nil, err
+	}
+
+	return func(field reflect.Value, bound prometheus.Labels) {
+		field.Set(reflect.MakeFunc(field.Type(), func([]reflect.Value) []reflect.Value {
+			for _, collector := range metric.collectors() {
+				if len(bound) > 0 {
+					if d, ok := collector.(deleter); ok {
+						deleteMatching(collector, d, labelNames, bound)
+					} else {
+						in.handleError(name, resetErrorKind, fmt.Errorf("field %s: collector %T of metric %s can't delete series", path, collector, target))
+					}
+				} else if r, ok := collector.(resetter); ok {
+					r.Reset()
+				} else {
+					in.handleError(name, resetErrorKind, fmt.Errorf("field %s: collector %T of metric %s can't be reset", path, collector, target))
+				}
+			}
+			return nil
+		}))
+	}, nil
+}
+
+// deleteMatching deletes the series collected from the collector provided whose labels have the values bound provided,
+// the series are deleted by the values of the label names provided, leaving out the const labels.
+func deleteMatching(collector prometheus.Collector, d deleter, labelNames []string, bound prometheus.Labels) {
+	for _, series := range collectSeries([]prometheus.Collector{collector}) {
+		matches := true
+		for name, value := range bound {
+			if series.Labels[name] != value {
+				matches = false
+				break
+			}
+		}
+		if !matches {
+			continue
+		}
+
+		values := make(prometheus.Labels, len(labelNames))
+		for _, name := range labelNames {
+			values[name] = series.Labels[name]
+		}
+		d.Delete(values)
+	}
+}
+
+// deleteCompanion returns the filler of a companion of the type provided deleting the series of the metric provided,
+// named like the name provided, whose metric func is of the metric type provided
+func (in *initializer) deleteCompanion(path, target, name string, metric builtMetric, companionType, metricType reflect.Type) (filler, error) {
+	if companionType.NumIn() != metricType.NumIn() || (companionType.NumIn() == 1 && companionType.In(0) != metricType.In(0)) {
+		return nil, fmt.Errorf("field %s: expected companion of metric %s to receive the same labels as the metric", path, target)
+	}
+	var labels []label
+	if companionType.NumIn() == 1 {
+		var err error
+		if labels, err = findLabels(path, companionType.In(0)); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkCollectors(path, target, metric, reflect.TypeOf((*deleter)(nil)).Elem(), "delete series"); err != nil {
+		return nil, err
+	}
+
+	return func(field reflect.Value, bound prometheus.Labels) {
+		field.Set(reflect.MakeFunc(field.Type(), func(args []reflect.Value) []reflect.Value {
+			values := bound
+			if len(args) == 1 {
+				values = mergeLabels(labelsFromValue(labels, args[0]), bound)
+			}
+
+			deleted := false
+			for _, collector := range metric.collectors() {
+				if d, ok := collector.(deleter); ok {
+					deleted = d.Delete(values) || deleted
+				} else {
+					in.handleError(name, deleteErrorKind, fmt.Errorf("field %s: collector %T of metric %s can't delete series", path, collector, target))
+				}
+			}
+			return []reflect.Value{reflect.ValueOf(deleted).Convert(field.Type().Out(0))}
+		}))
+	}, nil
+}
+
+// checkCollectors checks that the collectors of the metric provided implement the interface type provided,
+// which allows them to do what's described. Lazy metrics that weren't used yet have no collectors,
+// so they're checked when the companion is called.
+func checkCollectors(path, target string, metric builtMetric, ifaceType reflect.Type, what string) error {
+	for _, collector := range metric.collectors() {
+		if !reflect.TypeOf(collector).Implements(ifaceType) {
+			return fmt.Errorf("field %s: collector %T of metric %s can't %s", path, collector, target, what)
+		}
+	}
+	return nil
+}
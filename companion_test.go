@@ -0,0 +1,252 @@
+package gotoprom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_Companions(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(registry, opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		return initializer
+	}
+
+	type labels struct {
+		Queue string `label:"queue"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			DeletePending func(labels) bool               `metric:"Pending"`
+			Pending       func(labels) prometheus.Gauge   `name:"pending" help:"Pending messages"`
+			ResetSent     func()                          `metric:"Sent"`
+			Sent          func(labels) prometheus.Counter `name:"sent_total" help:"Sent messages"`
+		}
+		require.NoError(t, newInitializer(registry).Init(&metrics, "test"))
+
+		metrics.Pending(labels{Queue: "orders"}).Set(1)
+		metrics.Pending(labels{Queue: "refunds"}).Set(2)
+		metrics.Sent(labels{Queue: "orders"}).Inc()
+
+		assert.True(t, metrics.DeletePending(labels{Queue: "orders"}))
+		assert.False(t, metrics.DeletePending(labels{Queue: "orders"}))
+		metrics.ResetSent()
+
+		expected := `
+# HELP test_pending Pending messages
+# TYPE test_pending gauge
+test_pending{queue="refunds"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("group labels", func(t *testing.T) {
+		type groupLabels struct {
+			Tenant string `label:"tenant"`
+		}
+		type group struct {
+			With          func(groupLabels) group
+			Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+			DeletePending func(labels) bool             `metric:"Pending"`
+			Workers       func() prometheus.Gauge       `name:"workers" help:"Workers"`
+			DeleteWorkers func() bool                   `metric:"Workers"`
+		}
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			Group group `namespace:"group"`
+		}
+		require.NoError(t, newInitializer(registry).Init(&metrics, "test"))
+
+		acme, globex := metrics.Group.With(groupLabels{Tenant: "acme"}), metrics.Group.With(groupLabels{Tenant: "globex"})
+		acme.Pending(labels{Queue: "orders"}).Set(1)
+		globex.Pending(labels{Queue: "orders"}).Set(2)
+		acme.Workers().Set(3)
+		globex.Workers().Set(4)
+
+		assert.True(t, acme.DeletePending(labels{Queue: "orders"}))
+		assert.True(t, globex.DeleteWorkers())
+
+		expected := `
+# HELP test_group_pending Pending messages
+# TYPE test_group_pending gauge
+test_group_pending{queue="orders",tenant="globex"} 2
+# HELP test_group_workers Workers
+# TYPE test_group_workers gauge
+test_group_workers{tenant="acme"} 3
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("reset with group labels", func(t *testing.T) {
+		type groupLabels struct {
+			Tenant string `label:"tenant"`
+		}
+		type group struct {
+			With         func(groupLabels) group
+			Pending      func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+			ResetPending func()                        `metric:"Pending"`
+		}
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			Group group `namespace:"group"`
+		}
+		require.NoError(t, newInitializer(registry, WithConstLabels(prometheus.Labels{"env": "test"})).Init(&metrics, "test"))
+
+		acme, globex := metrics.Group.With(groupLabels{Tenant: "acme"}), metrics.Group.With(groupLabels{Tenant: "globex"})
+		acme.Pending(labels{Queue: "orders"}).Set(1)
+		acme.Pending(labels{Queue: "refunds"}).Set(2)
+		globex.Pending(labels{Queue: "orders"}).Set(3)
+
+		acme.ResetPending()
+
+		expected := `
+# HELP test_group_pending Pending messages
+# TYPE test_group_pending gauge
+test_group_pending{env="test",queue="orders",tenant="globex"} 3
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("bundles", func(t *testing.T) {
+		type bundle struct {
+			Sent   prometheus.Counter `name:"sent_total" help:"Sent messages"`
+			Failed prometheus.Counter `name:"failed_total" help:"Failed messages"`
+		}
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			Messages       func(labels) bundle `name:"messages"`
+			DeleteMessages func(labels) bool   `metric:"Messages"`
+		}
+		require.NoError(t, newInitializer(registry).Init(&metrics, "test"))
+
+		metrics.Messages(labels{Queue: "orders"}).Sent.Inc()
+		assert.True(t, metrics.DeleteMessages(labels{Queue: "orders"}))
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader("")))
+	})
+
+	t.Run("lazy metrics", func(t *testing.T) {
+		var metrics struct {
+			Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages" lazy:"true"`
+			DeletePending func(labels) bool             `metric:"Pending"`
+		}
+		require.NoError(t, newInitializer(prometheus.NewRegistry()).Init(&metrics, "test"))
+
+		assert.False(t, metrics.DeletePending(labels{Queue: "orders"}))
+		metrics.Pending(labels{Queue: "orders"}).Set(1)
+		assert.True(t, metrics.DeletePending(labels{Queue: "orders"}))
+	})
+
+	t.Run("collector that can't delete series", func(t *testing.T) {
+		builder := func(ctx BuildContext) (BuildResult, error) {
+			gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: ctx.Name, Help: ctx.Help, Namespace: ctx.Namespace})
+			return BuildResult{
+				Factory:   func(prometheus.Labels) interface{} { return gauge },
+				Collector: gauge,
+			}, nil
+		}
+
+		var metrics struct {
+			Pending       func() prometheus.Gauge `name:"pending" help:"Pending messages"`
+			DeletePending func() bool             `metric:"Pending"`
+		}
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilderV2(prometheusvanilla.GaugeType, builder)
+		assert.Error(t, initializer.Init(&metrics, "test"))
+
+		var lazyMetrics struct {
+			Pending       func() prometheus.Gauge `name:"pending" help:"Pending messages" lazy:"true"`
+			DeletePending func() bool             `metric:"Pending"`
+		}
+		var handled []error
+		initializer = NewInitializer(prometheus.NewRegistry(), WithErrorHandler(func(err error) { handled = append(handled, err) }))
+		initializer.MustAddBuilderV2(prometheusvanilla.GaugeType, builder)
+		require.NoError(t, initializer.Init(&lazyMetrics, "test"))
+
+		lazyMetrics.Pending().Set(1)
+		assert.False(t, lazyMetrics.DeletePending())
+		assert.Len(t, handled, 1)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		type otherLabels struct {
+			Topic string `label:"topic"`
+		}
+
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "unknown metric",
+				metrics: &struct {
+					DeletePending func(labels) bool `metric:"Pending"`
+				}{},
+			},
+			{
+				desc: "metric is not a metric func",
+				metrics: &struct {
+					Pending       GaugeFunc         `name:"pending" help:"Pending messages"`
+					DeletePending func(labels) bool `metric:"Pending"`
+				}{},
+			},
+			{
+				desc: "different labels",
+				metrics: &struct {
+					Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+					DeletePending func(otherLabels) bool        `metric:"Pending"`
+				}{},
+			},
+			{
+				desc: "missing labels",
+				metrics: &struct {
+					Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+					DeletePending func() bool                   `metric:"Pending"`
+				}{},
+			},
+			{
+				desc: "unexpected signature",
+				metrics: &struct {
+					Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+					DeletePending func(labels) int              `metric:"Pending"`
+				}{},
+			},
+			{
+				desc: "unknown tag key",
+				metrics: &struct {
+					Pending       func(labels) prometheus.Gauge `name:"pending" help:"Pending messages"`
+					DeletePending func(labels) bool             `metric:"Pending" name:"delete"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				err := newInitializer(prometheus.NewRegistry(), WithStrictTagKeys()).Init(tc.metrics, "test")
+				assert.Error(t, err)
+			})
+		}
+
+		t.Run("metric failed", func(t *testing.T) {
+			var metrics struct {
+				Pending       func(labels) prometheus.Gauge `name:"pending"`
+				DeletePending func(labels) bool             `metric:"Pending"`
+			}
+			err := newInitializer(prometheus.NewRegistry()).Init(&metrics, "test")
+			var initErr InitError
+			require.True(t, errors.As(err, &initErr))
+			assert.Len(t, initErr.Errors, 1)
+		})
+	})
+}
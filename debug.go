@@ -0,0 +1,235 @@
+package gotoprom
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// debugSeriesLimit is the maximum number of series of each metric shown by the DebugHandler
+	debugSeriesLimit = 100
+	// debugTopValuesLimit is the maximum number of values of each label shown by the DebugHandler
+	debugTopValuesLimit = 5
+)
+
+// declaredMetric is a metric initialized by an Initializer
+type declaredMetric struct {
+	fieldPath  string
+	name       string
+	typ        string
+	help       string
+	labelNames []string
+	registries []string
+	lazy       bool
+	// collectors returns the collectors registered for the metric, lazy metrics have none until they're first used
+	collectors func() []prometheus.Collector
+	// series returns the number of series of the metric without collecting it, or false if they can't be counted
+	series func() (int, bool)
+}
+
+// declare records a metric initialized by the Initializer, so the DebugHandler can describe it
+func (in *initializer) declare(metric declaredMetric) {
+	in.declaredMu.Lock()
+	defer in.declaredMu.Unlock()
+
+	in.declared = append(in.declared, metric)
+}
+
+// declaredMetrics returns the metrics initialized by the Initializer, in the order they were initialized
+func (in *initializer) declaredMetrics() []declaredMetric {
+	in.declaredMu.Lock()
+	defer in.declaredMu.Unlock()
+
+	return append([]declaredMetric(nil), in.declared...)
+}
+
+// DebugHandler returns an http.Handler serving a page that describes the metrics initialized by the Initializer provided,
+// with their current series and values. The page is served as JSON if the request has the format=json query parameter
+// or accepts application/json, and as HTML otherwise.
+// Initializers not created by NewInitializer can't be described, and the handler fails for them.
+func DebugHandler(in Initializer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		described, ok := in.(*initializer)
+		if !ok {
+			http.Error(w, "gotoprom: can only describe the metrics of an Initializer created by NewInitializer", http.StatusNotImplemented)
+			return
+		}
+
+		metrics := debugMetrics(described.declaredMetrics())
+		// The page is rendered before writing it, so the errors can still be served
+		var page bytes.Buffer
+		contentType := "text/html; charset=utf-8"
+		var err error
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			contentType = "application/json"
+			err = json.NewEncoder(&page).Encode(metrics)
+		} else {
+			err = debugTemplate.Execute(&page, metrics)
+		}
+		if err != nil {
+			http.Error(w, fmt.Sprintf("gotoprom: describe the metrics: %s", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_, _ = page.WriteTo(w)
+	})
+}
+
+// debugMetric describes a metric in the page served by the DebugHandler
+type debugMetric struct {
+	FieldPath   string        `json:"field_path"`
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Help        string        `json:"help"`
+	LabelNames  []string      `json:"label_names"`
+	Registries  []string      `json:"registries"`
+	Lazy        bool          `json:"lazy"`
+	Registered  bool          `json:"registered"`
+	SeriesCount int           `json:"series_count"`
+	Labels      []debugLabel  `json:"labels"`
+	Series      []debugSeries `json:"series"`
+}
+
+// debugLabel describes the values of a label of a metric
+type debugLabel struct {
+	Name string `json:"name"`
+	// Cardinality is the number of different values of the label
+	Cardinality int `json:"cardinality"`
+	// TopValues are the values with the most series
+	TopValues []debugLabelValue `json:"top_values"`
+}
+
+// debugLabelValue is a value of a label and its number of series
+type debugLabelValue struct {
+	Value  string `json:"value"`
+	Series int    `json:"series"`
+}
+
+// debugSeries is a series of a metric and its current value
+type debugSeries struct {
+	Labels   map[string]string `json:"labels"`
+	Snapshot Snapshot          `json:"snapshot"`
+}
+
+// debugMetrics describes the metrics provided, collecting their current series
+func debugMetrics(declared []declaredMetric) []debugMetric {
+	metrics := make([]debugMetric, len(declared))
+	for i, d := range declared {
+		registries := d.registries
+		if len(registries) == 0 {
+			registries = []string{DefaultRegistry}
+		}
+		collectors := d.collectors()
+		series := collectSeries(collectors)
+
+		metrics[i] = debugMetric{
+			FieldPath:   d.fieldPath,
+			Name:        d.name,
+			Type:        d.typ,
+			Help:        d.help,
+			LabelNames:  d.labelNames,
+			Registries:  registries,
+			Lazy:        d.lazy,
+			Registered:  len(collectors) > 0,
+			SeriesCount: len(series),
+			Labels:      debugLabels(d.labelNames, series),
+			Series:      series,
+		}
+		if len(series) > debugSeriesLimit {
+			metrics[i].Series = series[:debugSeriesLimit]
+		}
+	}
+	return metrics
+}
+
+// collectSeries collects the series of the collectors provided, sorted by their labels
+func collectSeries(collectors []prometheus.Collector) []debugSeries {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		for _, collector := range collectors {
+			collector.Collect(ch)
+		}
+		close(ch)
+	}()
+
+	var series []debugSeries
+	for metric := range ch {
+		var m dto.Metric
+		if err := metric.Write(&m); err != nil {
+			continue
+		}
+		labels := make(map[string]string, len(m.GetLabel()))
+		for _, pair := range m.GetLabel() {
+			labels[pair.GetName()] = pair.GetValue()
+		}
+		series = append(series, debugSeries{Labels: labels, Snapshot: snapshotFromMetric(&m)})
+	}
+
+	sort.Slice(series, func(i, j int) bool { return seriesKey(series[i].Labels) < seriesKey(series[j].Labels) })
+	return series
+}
+
+// debugLabels describes the values of the labels provided in the series provided
+func debugLabels(labelNames []string, series []debugSeries) []debugLabel {
+	labels := make([]debugLabel, len(labelNames))
+	for i, name := range labelNames {
+		counts := make(map[string]int)
+		for _, s := range series {
+			counts[s.Labels[name]]++
+		}
+
+		values := make([]debugLabelValue, 0, len(counts))
+		for value, count := range counts {
+			values = append(values, debugLabelValue{Value: value, Series: count})
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if values[i].Series != values[j].Series {
+				return values[i].Series > values[j].Series
+			}
+			return values[i].Value < values[j].Value
+		})
+		if len(values) > debugTopValuesLimit {
+			values = values[:debugTopValuesLimit]
+		}
+		labels[i] = debugLabel{Name: name, Cardinality: len(counts), TopValues: values}
+	}
+	return labels
+}
+
+var debugTemplate = template.Must(template.New("debug").Parse(`<!DOCTYPE html>
+<html>
+<head><title>gotoprom metrics</title></head>
+<body>
+<h1>gotoprom metrics</h1>
+{{range .}}
+<h2>{{.Name}}</h2>
+<table>
+<tr><th>Field</th><td>{{.FieldPath}}</td></tr>
+<tr><th>Type</th><td>{{.Type}}</td></tr>
+<tr><th>Help</th><td>{{.Help}}</td></tr>
+<tr><th>Labels</th><td>{{range $i, $l := .Labels}}{{if $i}}, {{end}}{{$l.Name}} ({{$l.Cardinality}} values: {{range $j, $v := $l.TopValues}}{{if $j}}, {{end}}{{$v.Value}} ({{$v.Series}}){{end}}){{end}}</td></tr>
+<tr><th>Registries</th><td>{{range $i, $r := .Registries}}{{if $i}}, {{end}}{{$r}}{{end}}</td></tr>
+<tr><th>Registered</th><td>{{.Registered}}{{if .Lazy}} (lazy){{end}}</td></tr>
+<tr><th>Series</th><td>{{.SeriesCount}}</td></tr>
+</table>
+{{if .Series}}
+<table>
+<tr><th>Labels</th><th>Value</th></tr>
+{{range .Series}}
+<tr><td>{{range $name, $value := .Labels}}{{$name}}="{{$value}}" {{end}}</td><td>{{with .Snapshot}}{{if or (eq .Type "histogram") (eq .Type "summary")}}count={{.SampleCount}} sum={{.SampleSum}}{{else}}{{.Value}}{{end}}{{end}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
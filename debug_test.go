@@ -0,0 +1,122 @@
+package gotoprom
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+	initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+	type labels struct {
+		Code   int    `label:"code"`
+		Method string `label:"method"`
+	}
+	var metrics struct {
+		Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+		Cache    struct {
+			Size func() prometheus.Gauge `name:"size" help:"Size" lazy:"true"`
+		} `namespace:"cache"`
+		Uptime GaugeFunc `name:"uptime_seconds" help:"Uptime"`
+	}
+	require.NoError(t, initializer.Init(&metrics, "test"))
+
+	metrics.Requests(labels{Code: 200, Method: "GET"}).Add(2)
+	metrics.Requests(labels{Code: 200, Method: "POST"}).Inc()
+	metrics.Requests(labels{Code: 500, Method: "GET"}).Inc()
+	metrics.Uptime = func() float64 { return 60 }
+
+	t.Run("json", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		DebugHandler(initializer).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/metrics?format=json", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+
+		var described []debugMetric
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&described))
+		require.Len(t, described, 3)
+
+		requests := described[0]
+		assert.Equal(t, "Requests", requests.FieldPath)
+		assert.Equal(t, "test_requests_total", requests.Name)
+		assert.Equal(t, "prometheus.Counter", requests.Type)
+		assert.Equal(t, "Requests", requests.Help)
+		assert.Equal(t, []string{"code", "method"}, requests.LabelNames)
+		assert.Equal(t, []string{DefaultRegistry}, requests.Registries)
+		assert.True(t, requests.Registered)
+		assert.Equal(t, 3, requests.SeriesCount)
+		assert.Equal(t, []debugLabel{
+			{Name: "code", Cardinality: 2, TopValues: []debugLabelValue{{Value: "200", Series: 2}, {Value: "500", Series: 1}}},
+			{Name: "method", Cardinality: 2, TopValues: []debugLabelValue{{Value: "GET", Series: 2}, {Value: "POST", Series: 1}}},
+		}, requests.Labels)
+		assert.Equal(t, debugSeries{
+			Labels:   map[string]string{"code": "200", "method": "GET"},
+			Snapshot: Snapshot{Type: "counter", Value: 2},
+		}, requests.Series[0])
+
+		size := described[1]
+		assert.Equal(t, "Cache.Size", size.FieldPath)
+		assert.Equal(t, "test_cache_size", size.Name)
+		assert.True(t, size.Lazy)
+		assert.False(t, size.Registered)
+		assert.Zero(t, size.SeriesCount)
+
+		uptime := described[2]
+		assert.Equal(t, "gotoprom.GaugeFunc", uptime.Type)
+		assert.Equal(t, []debugSeries{{Labels: map[string]string{}, Snapshot: Snapshot{Type: "gauge", Value: 60}}}, uptime.Series)
+	})
+
+	t.Run("html", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		DebugHandler(initializer).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/metrics", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "text/html; charset=utf-8", rec.Header().Get("Content-Type"))
+		assert.Contains(t, rec.Body.String(), "test_requests_total")
+		assert.Contains(t, rec.Body.String(), "Cache.Size")
+	})
+
+	t.Run("values that aren't finite", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		initializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+
+		var metrics struct {
+			Latency func() prometheus.Summary `name:"latency_seconds" help:"Latency" objectives:"0.5"`
+			Ratio   func() prometheus.Gauge   `name:"ratio" help:"Ratio"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+		metrics.Latency()
+		metrics.Ratio().Set(math.Inf(1))
+
+		rec := httptest.NewRecorder()
+		DebugHandler(initializer).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/metrics?format=json", nil))
+		require.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"value":"NaN"`)
+		assert.Contains(t, rec.Body.String(), `"value":"+Inf"`)
+
+		var described []debugMetric
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&described))
+		require.Len(t, described, 2)
+		require.Len(t, described[0].Series, 1)
+		require.Len(t, described[0].Series[0].Snapshot.Quantiles, 1)
+		assert.True(t, math.IsNaN(described[0].Series[0].Snapshot.Quantiles[0].Value))
+		require.Len(t, described[1].Series, 1)
+		assert.True(t, math.IsInf(described[1].Series[0].Snapshot.Value, 1))
+	})
+
+	t.Run("initializer not created by NewInitializer", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		DebugHandler(&InitializerMock{}).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/metrics", nil))
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+	})
+}
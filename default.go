@@ -1,20 +1,38 @@
 package gotoprom
 
 import (
+	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 // DefaultInitializer is the instance instance of the Initializer used by default
-var DefaultInitializer = NewInitializer(prometheus.DefaultRegisterer)
+var DefaultInitializer Initializer = NewInitializer(prometheus.DefaultRegisterer)
 
 func init() {
-	DefaultInitializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
-	DefaultInitializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
-	DefaultInitializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
-	DefaultInitializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+	in := defaultV2()
+	in.MustAddBuilderV2(prometheusvanilla.HistogramType, DeclareTagKeys(prometheusvanilla.BuildHistogram, prometheusvanilla.HistogramTagKeys...))
+	in.MustAddBuilderV2(prometheusvanilla.CounterType, DeclareTagKeys(prometheusvanilla.BuildCounter, prometheusvanilla.CounterTagKeys...))
+	in.MustAddBuilderV2(prometheusvanilla.GaugeType, DeclareTagKeys(prometheusvanilla.BuildGauge, prometheusvanilla.GaugeTagKeys...))
+	in.MustAddBuilderV2(prometheusvanilla.SummaryType, DeclareTagKeys(prometheusvanilla.BuildSummary, prometheusvanilla.SummaryTagKeys...))
+
+	in.MustAddBuilderV2(prometheusx.TimeHistogramType, DeclareTagKeys(prometheusx.BuildTimeHistogram, prometheusx.TimeHistogramTagKeys...))
+	in.MustAddBuilderV2(prometheusx.TimeSummaryType, DeclareTagKeys(prometheusx.BuildTimeSummary, prometheusx.TimeSummaryTagKeys...))
+	in.MustAddBuilderV2(prometheusx.InFlightGaugeType, DeclareTagKeys(prometheusx.BuildInFlightGauge, prometheusx.InFlightGaugeTagKeys...))
+}
+
+// defaultV2 returns the DefaultInitializer as an InitializerV2,
+// it panics if the DefaultInitializer was replaced by an Initializer that isn't one
+func defaultV2() InitializerV2 {
+	in, ok := DefaultInitializer.(InitializerV2)
+	if !ok {
+		panic(fmt.Sprintf("gotoprom: DefaultInitializer %T is not an InitializerV2", DefaultInitializer))
+	}
+	return in
 }
 
 // MustAddBuilder will AddBuilder and panic if an error occurs
@@ -27,6 +45,52 @@ func AddBuilder(typ reflect.Type, registerer Builder) error {
 	return DefaultInitializer.AddBuilder(typ, registerer)
 }
 
+// MustAddBuilderV2 will AddBuilderV2 and panic if an error occurs
+func MustAddBuilderV2(typ reflect.Type, builder BuilderV2) {
+	defaultV2().MustAddBuilderV2(typ, builder)
+}
+
+// AddBuilderV2 adds a new BuilderV2 for type typ.
+func AddBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	return defaultV2().AddBuilderV2(typ, builder)
+}
+
+// ReplaceBuilder replaces the builder for type typ, which should already have one.
+func ReplaceBuilder(typ reflect.Type, builder Builder) error {
+	return defaultV2().ReplaceBuilder(typ, builder)
+}
+
+// ReplaceBuilderV2 replaces the builder for type typ with a BuilderV2, typ should already have a builder.
+func ReplaceBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	return defaultV2().ReplaceBuilderV2(typ, builder)
+}
+
+// RemoveBuilder removes the builder for type typ, which should already have one.
+func RemoveBuilder(typ reflect.Type) error {
+	return defaultV2().RemoveBuilder(typ)
+}
+
+// Builders returns the types that have a builder, sorted by their names.
+func Builders() []reflect.Type {
+	return defaultV2().Builders()
+}
+
+// Use wraps all the builders with the Middleware provided when building the metrics initialized afterwards.
+func Use(mw Middleware) {
+	defaultV2().Use(mw)
+}
+
+// UseV2 wraps all the builders with the MiddlewareV2 provided, like Use does.
+func UseV2(mw MiddlewareV2) {
+	defaultV2().UseV2(mw)
+}
+
+// Clone returns a new Initializer with the same registerers, options, builders and middlewares as the DefaultInitializer,
+// builders and middlewares added to or removed from the clone don't affect the DefaultInitializer and vice versa.
+func Clone() InitializerV2 {
+	return defaultV2().Clone()
+}
+
 // MustInit initializes the metrics or panics.
 func MustInit(metrics interface{}, namespace string) {
 	DefaultInitializer.MustInit(metrics, namespace)
@@ -36,3 +100,27 @@ func MustInit(metrics interface{}, namespace string) {
 func Init(metrics interface{}, namespace string) error {
 	return DefaultInitializer.Init(metrics, namespace)
 }
+
+// MustInitInstance initializes an instance of the metrics or panics.
+func MustInitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) {
+	defaultV2().MustInitInstance(metrics, namespace, instanceLabels)
+}
+
+// InitInstance initializes the metrics in the given namespace, reporting the instance labels provided in all of them.
+// The metrics are registered once for all the instances of the same metrics struct type, namespace and instance label names.
+// Callbacks can't be declared in the metrics of an instance.
+func InitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) error {
+	return defaultV2().InitInstance(metrics, namespace, instanceLabels)
+}
+
+// Validate checks the metrics like Init does, but without registering them or setting the metric functions.
+// The metrics are also checked not to collide with the ones gathered from the gatherers provided.
+func Validate(metrics interface{}, namespace string, against ...prometheus.Gatherer) error {
+	return defaultV2().Validate(metrics, namespace, against...)
+}
+
+// StartSweeper starts deleting the idle series of the metrics with the ttl tag every interval provided,
+// besides deleting them when they're collected. It returns a function that stops the sweeper.
+func StartSweeper(interval time.Duration) (stop func()) {
+	return defaultV2().StartSweeper(interval)
+}
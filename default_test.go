@@ -4,8 +4,10 @@ import (
 	"errors"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -62,6 +64,145 @@ func TestAddBuilder(t *testing.T) {
 	assert.Equal(t, expectedErr, err)
 }
 
+func TestMustAddBuilderV2(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+
+	typ := prometheusvanilla.HistogramType
+	builder := func(BuildContext) (BuildResult, error) {
+		return BuildResult{}, expectedErr
+	}
+
+	initializerMock.On("MustAddBuilderV2", typ, mock.Anything).Run(func(args mock.Arguments) {
+		// we can't assert that two functions are the same, so we invoke it and see if it's ours
+		_, err := args[1].(BuilderV2)(BuildContext{})
+		assert.Equal(t, expectedErr, err)
+	}).Once()
+
+	MustAddBuilderV2(typ, builder)
+}
+
+func TestAddBuilderV2(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+
+	typ := prometheusvanilla.HistogramType
+	builder := func(BuildContext) (BuildResult, error) {
+		return BuildResult{}, expectedErr
+	}
+
+	initializerMock.On("AddBuilderV2", typ, mock.Anything).Run(func(args mock.Arguments) {
+		// we can't assert that two functions are the same, so we invoke it and see if it's ours
+		_, err := args[1].(BuilderV2)(BuildContext{})
+		assert.Equal(t, expectedErr, err)
+	}).Return(expectedErr).Once()
+
+	err := AddBuilderV2(typ, builder)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestReplaceBuilder(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+	typ := prometheusvanilla.HistogramType
+
+	initializerMock.On("ReplaceBuilder", typ, mock.Anything).Return(expectedErr).Once()
+
+	err := ReplaceBuilder(typ, prometheusvanilla.BuildHistogram)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestReplaceBuilderV2(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+	typ := prometheusvanilla.HistogramType
+
+	initializerMock.On("ReplaceBuilderV2", typ, mock.Anything).Return(expectedErr).Once()
+
+	err := ReplaceBuilderV2(typ, func(BuildContext) (BuildResult, error) { return BuildResult{}, nil })
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestRemoveBuilder(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+	typ := prometheusvanilla.HistogramType
+
+	initializerMock.On("RemoveBuilder", typ).Return(expectedErr).Once()
+
+	err := RemoveBuilder(typ)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestBuilders(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expected := []reflect.Type{prometheusvanilla.HistogramType}
+	initializerMock.On("Builders").Return(expected).Once()
+
+	assert.Equal(t, expected, Builders())
+}
+
+func TestUse(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	initializerMock.On("Use", mock.Anything).Once()
+
+	Use(func(b Builder) Builder { return b })
+}
+
+func TestUseV2(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	initializerMock.On("UseV2", mock.Anything).Once()
+
+	UseV2(func(b BuilderV2) BuilderV2 { return b })
+}
+
+func TestDefaultInitializer_Builders(t *testing.T) {
+	assert.Equal(t, []reflect.Type{
+		prometheusvanilla.CounterType,
+		prometheusvanilla.GaugeType,
+		prometheusvanilla.HistogramType,
+		prometheusvanilla.SummaryType,
+		prometheusx.InFlightGaugeType,
+		prometheusx.TimeHistogramType,
+		prometheusx.TimeSummaryType,
+	}, defaultV2().Builders())
+}
+
+func TestClone(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	clone := &InitializerMock{}
+	initializerMock.On("Clone").Return(clone).Once()
+
+	assert.Equal(t, clone, Clone())
+}
+
 func TestInit(t *testing.T) {
 	initializerMock, tearDown := mockDefaultInitializer()
 	defer tearDown()
@@ -78,6 +219,23 @@ func TestInit(t *testing.T) {
 	assert.Equal(t, expectedErr, err)
 }
 
+func TestValidate(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+
+	metrics := struct{ whatever int }{}
+	namespace := "some namespace"
+	against := []prometheus.Gatherer{prometheus.NewRegistry()}
+
+	initializerMock.On("Validate", metrics, namespace, against).Return(expectedErr).Once()
+
+	err := Validate(metrics, namespace, against...)
+	assert.Equal(t, expectedErr, err)
+}
+
 func TestMustInit(t *testing.T) {
 	initializerMock, tearDown := mockDefaultInitializer()
 	defer tearDown()
@@ -91,6 +249,64 @@ func TestMustInit(t *testing.T) {
 	MustInit(metrics, namespace)
 }
 
+func TestInitInstance(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+
+	metrics := struct{ whatever int }{}
+	namespace := "some namespace"
+	instanceLabels := prometheus.Labels{"tenant": "acme"}
+
+	initializerMock.On("InitInstance", metrics, namespace, instanceLabels).Return(expectedErr).Once()
+
+	err := InitInstance(metrics, namespace, instanceLabels)
+	assert.Equal(t, expectedErr, err)
+}
+
+func TestMustInitInstance(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	metrics := struct{ whatever int }{}
+	namespace := "some namespace"
+	instanceLabels := prometheus.Labels{"tenant": "acme"}
+
+	initializerMock.On("MustInitInstance", metrics, namespace, instanceLabels).Once()
+
+	MustInitInstance(metrics, namespace, instanceLabels)
+}
+
+func TestStartSweeper(t *testing.T) {
+	initializerMock, tearDown := mockDefaultInitializer()
+	defer tearDown()
+	defer initializerMock.AssertExpectations(t)
+
+	stopped := false
+	initializerMock.On("StartSweeper", time.Minute).Return(func() { stopped = true }).Once()
+
+	StartSweeper(time.Minute)()
+	assert.True(t, stopped)
+}
+
+func TestDefaultInitializer_NotInitializerV2(t *testing.T) {
+	original := DefaultInitializer
+	defer func() { DefaultInitializer = original }()
+
+	// An Initializer implementing only the Initializer methods, like the ones written before InitializerV2
+	initializerMock := &InitializerMock{}
+	DefaultInitializer = struct{ Initializer }{initializerMock}
+	defer initializerMock.AssertExpectations(t)
+
+	expectedErr := errors.New("my err")
+	initializerMock.On("Init", mock.Anything, "test").Return(expectedErr).Once()
+	assert.Equal(t, expectedErr, Init(&struct{}{}, "test"))
+	assert.Panics(t, func() { Use(func(b Builder) Builder { return b }) })
+}
+
 func mockDefaultInitializer() (mock *InitializerMock, tearDown func()) {
 	original := DefaultInitializer
 	mock = &InitializerMock{}
@@ -111,6 +327,48 @@ func (m *InitializerMock) AddBuilder(typ reflect.Type, registerer Builder) error
 	return ret[0].(error)
 }
 
+func (m *InitializerMock) MustAddBuilderV2(typ reflect.Type, builder BuilderV2) {
+	m.Called(typ, builder)
+}
+
+func (m *InitializerMock) AddBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	ret := m.Called(typ, builder)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) ReplaceBuilder(typ reflect.Type, builder Builder) error {
+	ret := m.Called(typ, builder)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) ReplaceBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	ret := m.Called(typ, builder)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) RemoveBuilder(typ reflect.Type) error {
+	ret := m.Called(typ)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) Builders() []reflect.Type {
+	ret := m.Called()
+	return ret.Get(0).([]reflect.Type)
+}
+
+func (m *InitializerMock) Use(mw Middleware) {
+	m.Called(mw)
+}
+
+func (m *InitializerMock) UseV2(mw MiddlewareV2) {
+	m.Called(mw)
+}
+
+func (m *InitializerMock) Clone() InitializerV2 {
+	ret := m.Called()
+	return ret.Get(0).(InitializerV2)
+}
+
 func (m *InitializerMock) MustInit(metrics interface{}, namespace string) {
 	m.Called(metrics, namespace)
 }
@@ -119,3 +377,22 @@ func (m *InitializerMock) Init(metrics interface{}, namespace string) error {
 	ret := m.Called(metrics, namespace)
 	return ret[0].(error)
 }
+
+func (m *InitializerMock) MustInitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) {
+	m.Called(metrics, namespace, instanceLabels)
+}
+
+func (m *InitializerMock) InitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) error {
+	ret := m.Called(metrics, namespace, instanceLabels)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) Validate(metrics interface{}, namespace string, against ...prometheus.Gatherer) error {
+	ret := m.Called(metrics, namespace, against)
+	return ret.Error(0)
+}
+
+func (m *InitializerMock) StartSweeper(interval time.Duration) (stop func()) {
+	ret := m.Called(interval)
+	return ret.Get(0).(func())
+}
@@ -0,0 +1,165 @@
+package gotoprom
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// InitError holds all the errors found by Init, in the order of the fields they were found in.
+// The errors can be inspected with errors.Is and errors.As, like errors.Is(err, ErrMissingTag{Tag: "help"})
+type InitError struct {
+	Errors []error
+}
+
+// Error implements error
+func (e InitError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors initializing metrics: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the errors found, so errors.Is and errors.As check all of them since Go 1.20
+func (e InitError) Unwrap() []error {
+	return e.Errors
+}
+
+// Is tells whether any of the errors found matches the target, so errors.Is checks all of them before Go 1.20
+func (e InitError) Is(target error) bool {
+	for _, err := range e.Errors {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors found that matches the target, so errors.As checks all of them before Go 1.20
+func (e InitError) As(target interface{}) bool {
+	for _, err := range e.Errors {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// initErrors collects the errors found while initializing the metrics
+type initErrors []error
+
+// add adds the error provided, if any, flattening the errors of an InitError
+func (errs *initErrors) add(err error) {
+	if err == nil {
+		return
+	}
+	if initErr, ok := err.(InitError); ok {
+		*errs = append(*errs, initErr.Errors...)
+		return
+	}
+	*errs = append(*errs, err)
+}
+
+// err returns an InitError with the errors collected, or nil if there's none
+func (errs initErrors) err() error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return InitError{Errors: errs}
+}
+
+// ErrMissingTag is the error found when a field lacks a required tag.
+// The Field of the errors found in label fields is the path of the metric followed by the path of the label field,
+// like Requests.Total.Code
+type ErrMissingTag struct {
+	// Field is the path of the field, like Requests.Total
+	Field string
+	// Tag is the tag missing, like help
+	Tag string
+}
+
+// Error implements error
+func (e ErrMissingTag) Error() string {
+	return fmt.Sprintf("%s tag for %s missing", e.Tag, e.Field)
+}
+
+// Is tells whether the target is an ErrMissingTag for the same field and tag, empty values in the target match any
+func (e ErrMissingTag) Is(target error) bool {
+	t, ok := target.(ErrMissingTag)
+	return ok && (t.Field == "" || t.Field == e.Field) && (t.Tag == "" || t.Tag == e.Tag)
+}
+
+// ErrUnsupportedLabelKind is the error found when a label field is of a kind that can't be used as a label
+type ErrUnsupportedLabelKind struct {
+	// Field is the path of the metric, like Requests.Total
+	Field string
+	// Label is the name of the label
+	Label string
+	// Kind is the kind of the label field
+	Kind reflect.Kind
+}
+
+// Error implements error
+func (e ErrUnsupportedLabelKind) Error() string {
+	return fmt.Sprintf("field %s: label %s has unsupported type %v", e.Field, e.Label, e.Kind)
+}
+
+// Is tells whether the target is an ErrUnsupportedLabelKind for the same field, label and kind,
+// empty values in the target match any
+func (e ErrUnsupportedLabelKind) Is(target error) bool {
+	t, ok := target.(ErrUnsupportedLabelKind)
+	return ok &&
+		(t.Field == "" || t.Field == e.Field) &&
+		(t.Label == "" || t.Label == e.Label) &&
+		(t.Kind == reflect.Invalid || t.Kind == e.Kind)
+}
+
+// ErrDuplicateLabel is the error found when a metric would have the same label twice,
+// because its labels struct declares it twice or because one of its groups already binds it
+type ErrDuplicateLabel struct {
+	// Field is the path of the metric or the group, like Requests.Total
+	Field string
+	// Label is the name of the label
+	Label string
+	// LabelFields are the paths of the label fields declaring the label, like Requests.Total.Code,
+	// the ones declared by the labels func of a group are like Requests.With.Code
+	LabelFields []string
+}
+
+// Error implements error
+func (e ErrDuplicateLabel) Error() string {
+	if len(e.LabelFields) > 0 {
+		return fmt.Sprintf("field %s: label %q can't be registered twice, it's declared by %s", e.Field, e.Label, strings.Join(e.LabelFields, " and "))
+	}
+	return fmt.Sprintf("field %s: label %q can't be registered twice", e.Field, e.Label)
+}
+
+// Is tells whether the target is an ErrDuplicateLabel for the same field and label, empty values in the target match any
+func (e ErrDuplicateLabel) Is(target error) bool {
+	t, ok := target.(ErrDuplicateLabel)
+	return ok && (t.Field == "" || t.Field == e.Field) && (t.Label == "" || t.Label == e.Label)
+}
+
+// ErrNoBuilder is the error found when there's no builder for the type of a metric
+type ErrNoBuilder struct {
+	// Field is the path of the metric, like Requests.Total
+	Field string
+	// Type is the type of the metric
+	Type reflect.Type
+}
+
+// Error implements error
+func (e ErrNoBuilder) Error() string {
+	return fmt.Sprintf("field %s: no builder found for type %q", e.Field, e.Type)
+}
+
+// Is tells whether the target is an ErrNoBuilder for the same field and type, empty values in the target match any
+func (e ErrNoBuilder) Is(target error) bool {
+	t, ok := target.(ErrNoBuilder)
+	return ok && (t.Field == "" || t.Field == e.Field) && (t.Type == nil || t.Type == e.Type)
+}
@@ -0,0 +1,142 @@
+package gotoprom
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_Errors(t *testing.T) {
+	type labels struct {
+		Code  string  `label:"code"`
+		Ratio float64 `label:"ratio"`
+	}
+	type duplicateLabels struct {
+		Code      string `label:"code"`
+		OtherCode string `label:"code"`
+	}
+	type untaggedLabels struct {
+		Code string
+	}
+
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+	var metrics struct {
+		Requests struct {
+			Total     func() prometheus.Counter                `name:"total"`
+			Errors    func(labels) prometheus.Counter          `name:"errors" help:"Errors"`
+			Retries   func(duplicateLabels) prometheus.Counter `name:"retries" help:"Retries"`
+			Untagged  func(untaggedLabels) prometheus.Counter  `name:"untagged" help:"Untagged"`
+			Durations func() prometheus.Histogram              `name:"durations" help:"Durations"`
+			OK        func() prometheus.Counter                `name:"ok" help:"Registered"`
+		} `namespace:"requests"`
+		Nested struct{}
+	}
+	err := initializer.Init(&metrics, "test")
+	require.Error(t, err)
+
+	var initErr InitError
+	require.True(t, errors.As(err, &initErr))
+	assert.Len(t, initErr.Errors, 6)
+
+	assert.True(t, errors.Is(err, ErrMissingTag{Field: "Requests.Total", Tag: "help"}))
+	assert.True(t, errors.Is(err, ErrUnsupportedLabelKind{Field: "Requests.Errors", Label: "ratio", Kind: reflect.Float64}))
+	assert.True(t, errors.Is(err, ErrDuplicateLabel{Field: "Requests.Retries", Label: "code"}))
+	assert.True(t, errors.Is(err, ErrMissingTag{Field: "Requests.Untagged.Code", Tag: "label"}))
+	assert.True(t, errors.Is(err, ErrNoBuilder{Field: "Requests.Durations", Type: prometheusvanilla.HistogramType}))
+	assert.True(t, errors.Is(err, ErrMissingTag{Field: "Nested", Tag: "namespace"}))
+
+	assert.True(t, errors.Is(err, ErrMissingTag{}), "empty values match any")
+	assert.False(t, errors.Is(err, ErrMissingTag{Field: "Requests.OK"}))
+
+	var noBuilder ErrNoBuilder
+	require.True(t, errors.As(err, &noBuilder))
+	assert.Equal(t, "Requests.Durations", noBuilder.Field)
+}
+
+func TestInitError_Error(t *testing.T) {
+	t.Run("one error", func(t *testing.T) {
+		err := InitError{Errors: []error{ErrMissingTag{Field: "Requests.Total", Tag: "help"}}}
+		assert.EqualError(t, err, "help tag for Requests.Total missing")
+	})
+
+	t.Run("several errors", func(t *testing.T) {
+		err := InitError{Errors: []error{
+			ErrMissingTag{Field: "Requests.Total", Tag: "help"},
+			ErrDuplicateLabel{Field: "Requests.Errors", Label: "code"},
+		}}
+		assert.EqualError(t, err, `2 errors initializing metrics: help tag for Requests.Total missing; field Requests.Errors: label "code" can't be registered twice`)
+	})
+}
+
+func TestInitError_IsAs(t *testing.T) {
+	// The methods are called directly, since errors.Is and errors.As don't need them since Go 1.20
+	err := InitError{Errors: []error{
+		ErrMissingTag{Field: "Requests.Total", Tag: "help"},
+		fmt.Errorf("wrapped: %w", ErrNoBuilder{Field: "Requests.Errors"}),
+	}}
+
+	assert.True(t, err.Is(ErrMissingTag{Tag: "help"}))
+	assert.True(t, err.Is(ErrNoBuilder{}))
+	assert.False(t, err.Is(ErrDuplicateLabel{}))
+
+	var noBuilder ErrNoBuilder
+	require.True(t, err.As(&noBuilder))
+	assert.Equal(t, "Requests.Errors", noBuilder.Field)
+	var duplicateLabel ErrDuplicateLabel
+	assert.False(t, err.As(&duplicateLabel))
+}
+
+func TestInitializer_Init_DuplicateLabels(t *testing.T) {
+	type baseLabels struct {
+		Code int `label:"code"`
+	}
+	type requestLabels struct {
+		Code string `label:"code" default:"none"`
+		baseLabels
+	}
+	type groupMetrics struct {
+		With func(struct {
+			Code string `label:"code"`
+		}) groupMetrics
+		Requests func(baseLabels) prometheus.Counter `name:"requests_total" help:"Requests"`
+	}
+
+	for _, tc := range []struct {
+		desc     string
+		metrics  interface{}
+		expected ErrDuplicateLabel
+	}{
+		{
+			desc: "different kinds and defaults in embedded structs",
+			metrics: &struct {
+				Requests func(requestLabels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			}{},
+			expected: ErrDuplicateLabel{Field: "Requests", Label: "code", LabelFields: []string{"Requests.Code", "Requests.baseLabels.Code"}},
+		},
+		{
+			desc: "bound by the group",
+			metrics: &struct {
+				Group groupMetrics `namespace:"group"`
+			}{},
+			expected: ErrDuplicateLabel{Field: "Group.Requests", Label: "code", LabelFields: []string{"Group.With.Code", "Group.Requests.Code"}},
+		},
+	} {
+		t.Run(tc.desc, func(t *testing.T) {
+			initializer := NewInitializer(prometheus.NewRegistry())
+			initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+			err := initializer.Init(tc.metrics, "test")
+			var duplicate ErrDuplicateLabel
+			require.True(t, errors.As(err, &duplicate), "expected %v, got %v", tc.expected, err)
+			assert.Equal(t, tc.expected, duplicate)
+		})
+	}
+}
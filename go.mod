@@ -1,9 +1,11 @@
 module github.com/cabify/gotoprom
 
-go 1.12
+go 1.13
 
 require (
 	github.com/prometheus/client_golang v1.11.1
+	github.com/prometheus/client_model v0.2.0
+	github.com/prometheus/common v0.26.0
 	github.com/stretchr/objx v0.2.0 // indirect
 	github.com/stretchr/testify v1.4.0
 )
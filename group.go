@@ -0,0 +1,225 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// filler sets the value of an initialized field, reporting the labels bound to it by its groups
+type filler func(field reflect.Value, bound prometheus.Labels)
+
+// scope holds what a group of metrics inherits from its parent groups
+type scope struct {
+	namespaces []string
+	// path holds the names of the fields leading to the group
+	path []string
+	// labels are the labels bound by the parent groups, in the order they're bound
+	labels []label
+	// labelNames are the names of the labels bound by the parent groups, in the same order as labels
+	labelNames []string
+	// labelTypes are the types of the label fields, in the same order as labelNames
+	labelTypes []reflect.Type
+	// instance tells whether the metrics are initialized by InitInstance, shared by all the instances of the metrics struct
+	instance bool
+	// lazy tells whether the metrics are built and registered the first time they're used
+	lazy bool
+	// registries are the names of the registries where the metrics are registered, the DefaultRegistry if it's empty
+	registries []string
+}
+
+// withNamespace returns a copy of the scope with the namespace provided appended
+func (s scope) withNamespace(namespace string) scope {
+	namespaces := make([]string, len(s.namespaces), len(s.namespaces)+1)
+	copy(namespaces, s.namespaces)
+	s.namespaces = append(namespaces, namespace)
+	return s
+}
+
+// withPath returns a copy of the scope with the field name provided appended to its path
+func (s scope) withPath(fieldName string) scope {
+	path := make([]string, len(s.path), len(s.path)+1)
+	copy(path, s.path)
+	s.path = append(path, fieldName)
+	return s
+}
+
+// fieldPath returns the path of the scope, like Requests.Total
+func (s scope) fieldPath() string {
+	return strings.Join(s.path, ".")
+}
+
+// withLazyTag returns a copy of the scope that is lazy or not as the lazy tag provided says, if it's defined
+func (s scope) withLazyTag(tag reflect.StructTag) (scope, error) {
+	lazy, ok := tag.Lookup("lazy")
+	if !ok {
+		return s, nil
+	}
+
+	var err error
+	if s.lazy, err = strconv.ParseBool(lazy); err != nil {
+		return s, fmt.Errorf("field %s: lazy tag should be a boolean, got %q", s.fieldPath(), lazy)
+	}
+	return s, nil
+}
+
+// withLabels returns a copy of the scope with the labels provided appended,
+// it fails if any of them was already bound by a parent group
+func (s scope) withLabels(labels []label) (scope, error) {
+	for _, l := range labels {
+		for _, bound := range s.labels {
+			if l.name == bound.name {
+				return s, ErrDuplicateLabel{Field: s.fieldPath(), Label: l.name, LabelFields: []string{bound.fieldPath, l.fieldPath}}
+			}
+		}
+	}
+
+	merged := make([]label, len(s.labels), len(s.labels)+len(labels))
+	copy(merged, s.labels)
+	s.labels = append(merged, labels...)
+	s.labelNames, s.labelTypes = labelNamesAndTypes(s.labels)
+	return s, nil
+}
+
+// groupLabels are the labels a group binds through its labels func,
+// a func field receiving a labels struct and returning a copy of the group
+// that reports those labels in all its metrics, like `With func(dbLabels) dbMetrics`
+type groupLabels struct {
+	fieldIndex int
+	funcType   reflect.Type
+	labels     []label
+}
+
+// findGroupLabels finds the labels func of the group type provided, declared in the scope provided,
+// it returns nil if the group doesn't bind any label
+func findGroupLabels(groupType reflect.Type, s scope) (*groupLabels, error) {
+	var found *groupLabels
+	for i := 0; i < groupType.NumField(); i++ {
+		f := groupType.Field(i)
+		if f.Type.Kind() != reflect.Func || f.Type.NumOut() != 1 || f.Type.Out(0) != groupType {
+			continue
+		}
+
+		path := s.withPath(f.Name).fieldPath()
+		if found != nil {
+			return nil, fmt.Errorf("group %s: fields %s and %s can't both bind the group labels", s.fieldPath(), groupType.Field(found.fieldIndex).Name, f.Name)
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("field %s needs be exported", path)
+		}
+		if f.Type.NumIn() != 1 {
+			return nil, fmt.Errorf("field %s: expected 1 in arg, got %d", path, f.Type.NumIn())
+		}
+
+		labels, err := findLabels(path, f.Type.In(0))
+		if err != nil {
+			return nil, err
+		}
+		found = &groupLabels{
+			fieldIndex: i,
+			funcType:   f.Type,
+			labels:     labels,
+		}
+	}
+	return found, nil
+}
+
+// defaults returns the labels reported by the group until it's scoped with its labels func,
+// which are the default values of the labels if all of them have one, or false otherwise,
+// since the metrics of the group can't be used until it's scoped then
+func (gl *groupLabels) defaults() (prometheus.Labels, bool) {
+	for _, l := range gl.labels {
+		if !l.hasDefaultValue {
+			return nil, false
+		}
+	}
+	return labelsFromValue(gl.labels, reflect.Zero(gl.funcType.In(0))), true
+}
+
+// fill sets the labels func of the group, which returns a copy of the group filled with the labels provided.
+// The copies of the label combinations used recently are cached, so the group isn't filled again on every call.
+func (gl *groupLabels) fill(group reflect.Value, bound prometheus.Labels, fillGroup filler) {
+	groupType := group.Type()
+	cache := &scopedGroups{}
+	group.Field(gl.fieldIndex).Set(reflect.MakeFunc(gl.funcType, func(args []reflect.Value) []reflect.Value {
+		labels := mergeLabels(bound, labelsFromValue(gl.labels, args[0]))
+		key := seriesKey(labels)
+		if scoped, ok := cache.load(key); ok {
+			return []reflect.Value{scoped}
+		}
+
+		scoped := reflect.New(groupType).Elem()
+		fillGroup(scoped, labels)
+		return []reflect.Value{cache.store(key, scoped)}
+	}))
+}
+
+// scopedGroupsCacheSize is the number of label combinations whose scoped copies of a group are cached in each generation
+const scopedGroupsCacheSize = 128
+
+// scopedGroups caches the scoped copies of a group by the key of their labels,
+// keeping the ones used in the current and the previous generations of scopedGroupsCacheSize label combinations,
+// so groups scoped with labels of high cardinality, like tenants or pods, don't keep a copy for each one of them
+type scopedGroups struct {
+	mu       sync.Mutex
+	current  map[string]reflect.Value
+	previous map[string]reflect.Value
+}
+
+// load returns the copy cached for the key provided, moving it to the current generation
+func (c *scopedGroups) load(key string) (reflect.Value, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if scoped, ok := c.current[key]; ok {
+		return scoped, true
+	}
+	scoped, ok := c.previous[key]
+	if ok {
+		c.add(key, scoped)
+	}
+	return scoped, ok
+}
+
+// store caches the copy provided for the key provided, unless another one was stored meanwhile,
+// and returns the copy cached
+func (c *scopedGroups) store(key string, scoped reflect.Value) reflect.Value {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if cached, ok := c.current[key]; ok {
+		return cached
+	}
+	if cached, ok := c.previous[key]; ok {
+		scoped = cached
+	}
+	c.add(key, scoped)
+	return scoped
+}
+
+// add adds the copy provided to the current generation, starting a new one if it's full
+func (c *scopedGroups) add(key string, scoped reflect.Value) {
+	if len(c.current) >= scopedGroupsCacheSize || c.current == nil {
+		c.previous, c.current = c.current, make(map[string]reflect.Value, scopedGroupsCacheSize)
+	}
+	c.current[key] = scoped
+}
+
+// mergeLabels returns a new prometheus.Labels with the labels provided, later ones override the former
+func mergeLabels(labels ...prometheus.Labels) prometheus.Labels {
+	size := 0
+	for _, l := range labels {
+		size += len(l)
+	}
+	merged := make(prometheus.Labels, size)
+	for _, l := range labels {
+		for name, value := range l {
+			merged[name] = value
+		}
+	}
+	return merged
+}
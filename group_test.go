@@ -0,0 +1,217 @@
+package gotoprom
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dbLabels struct {
+	Cluster string `label:"cluster" default:"none"`
+}
+
+type tableLabels struct {
+	Table string `label:"table"`
+}
+
+type queryLabels struct {
+	Operation string `label:"operation"`
+}
+
+type dbMetrics struct {
+	With    func(dbLabels) dbMetrics
+	Queries func(queryLabels) prometheus.Counter `name:"queries_total" help:"Queries executed"`
+	Tables  tableMetrics                         `namespace:"tables"`
+}
+
+type tableMetrics struct {
+	With func(tableLabels) tableMetrics
+	Rows func() prometheus.Gauge `name:"rows" help:"Rows in the table"`
+}
+
+func TestInitializer_Init_GroupLabels(t *testing.T) {
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var errs []error
+		initializer := NewInitializer(registry, WithErrorHandler(func(err error) { errs = append(errs, err) }))
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var metrics struct {
+			DB dbMetrics `namespace:"db"`
+		}
+		err := initializer.Init(&metrics, "test")
+		require.NoError(t, err)
+
+		main := metrics.DB.With(dbLabels{Cluster: "main"})
+		main.Queries(queryLabels{Operation: "select"}).Add(2)
+		main.Tables.With(tableLabels{Table: "users"}).Rows().Set(10)
+		main.With(dbLabels{Cluster: "replica"}).Queries(queryLabels{Operation: "select"}).Inc()
+		metrics.DB.Queries(queryLabels{Operation: "insert"}).Inc()
+		// The table label has no default value, so the tables can't be used without scoping them
+		metrics.DB.Tables.Rows().Set(1)
+		metrics.DB.Tables.Rows().Set(2)
+
+		expected := `
+# HELP test_db_queries_total Queries executed
+# TYPE test_db_queries_total counter
+test_db_queries_total{cluster="main",operation="select"} 2
+test_db_queries_total{cluster="none",operation="insert"} 1
+test_db_queries_total{cluster="replica",operation="select"} 1
+# HELP test_db_tables_rows Rows in the table
+# TYPE test_db_tables_rows gauge
+test_db_tables_rows{cluster="main",table="users"} 10
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+		assert.Len(t, errs, 1, "unscoped use is reported once")
+	})
+
+	t.Run("non string defaults", func(t *testing.T) {
+		type statusLabels struct {
+			Code    int  `label:"code" default:"200"`
+			Cached  bool `label:"cached" default:"true"`
+			Retries uint `label:"retries" default:"0"`
+		}
+		type statusMetrics struct {
+			With      func(statusLabels) statusMetrics
+			Responses func() prometheus.Counter `name:"responses_total" help:"Responses served"`
+		}
+
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+		var metrics struct {
+			Status statusMetrics `namespace:"status"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+
+		metrics.Status.Responses().Inc()
+		metrics.Status.With(statusLabels{Code: 404, Retries: 2}).Responses().Inc()
+
+		expected := `
+# HELP test_status_responses_total Responses served
+# TYPE test_status_responses_total counter
+test_status_responses_total{cached="true",code="200",retries="0"} 1
+test_status_responses_total{cached="true",code="404",retries="2"} 1
+`
+		err := testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+	})
+
+	t.Run("scoped groups are cached", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var metrics struct {
+			DB dbMetrics `namespace:"db"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+
+		main := metrics.DB.With(dbLabels{Cluster: "main"})
+		again := metrics.DB.With(dbLabels{Cluster: "main"})
+		assert.Equal(t, reflect.ValueOf(main.Queries), reflect.ValueOf(again.Queries), "same metric funcs")
+		assert.NotEqual(t, reflect.ValueOf(main.Queries), reflect.ValueOf(metrics.DB.With(dbLabels{Cluster: "replica"}).Queries))
+
+		for i := 0; i < 2*scopedGroupsCacheSize; i++ {
+			metrics.DB.With(dbLabels{Cluster: strconv.Itoa(i)})
+		}
+		evicted := metrics.DB.With(dbLabels{Cluster: "main"})
+		assert.NotEqual(t, reflect.ValueOf(main.Queries), reflect.ValueOf(evicted.Queries), "not used recently, so not cached")
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		type sameLabelMetrics struct {
+			With    func(dbLabels) sameLabelMetrics
+			Queries func(dbLabels) prometheus.Counter `name:"queries_total" help:"Cluster label is already bound"`
+		}
+		type nestedSameLabelMetrics struct {
+			With   func(dbLabels) nestedSameLabelMetrics
+			Nested dbMetrics `namespace:"nested"`
+		}
+		type twoLabelFuncsMetrics struct {
+			With    func(dbLabels) twoLabelFuncsMetrics
+			WithToo func(queryLabels) twoLabelFuncsMetrics
+		}
+		type callbackMetrics struct {
+			With  func(dbLabels) callbackMetrics
+			Depth GaugeFunc `name:"depth" help:"Callbacks can't be scoped"`
+		}
+		type wrongDefaultMetrics struct {
+			With func(struct {
+				Code int `label:"code" default:"none"`
+			}) wrongDefaultMetrics
+		}
+		type wrongLabelsMetrics struct {
+			With func(string) wrongLabelsMetrics
+		}
+		type noLabelsMetrics struct {
+			With func() noLabelsMetrics
+		}
+
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "metric label already bound by the group",
+				metrics: &struct {
+					DB sameLabelMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "nested group label already bound by the parent group",
+				metrics: &struct {
+					DB nestedSameLabelMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "two labels funcs",
+				metrics: &struct {
+					DB twoLabelFuncsMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "callback in a group with labels",
+				metrics: &struct {
+					DB callbackMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "default value not matching the label type",
+				metrics: &struct {
+					DB wrongDefaultMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "labels are not a struct",
+				metrics: &struct {
+					DB wrongLabelsMetrics `namespace:"db"`
+				}{},
+			},
+			{
+				desc: "labels func without labels",
+				metrics: &struct {
+					DB noLabelsMetrics `namespace:"db"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				initializer := NewInitializer(prometheus.NewRegistry())
+				initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+				err := initializer.Init(tc.metrics, "namespace")
+				assert.IsType(t, InitError{}, err)
+			})
+		}
+	})
+}
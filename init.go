@@ -3,8 +3,11 @@ package gotoprom
 import (
 	"fmt"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -35,23 +38,121 @@ type Initializer interface {
 	Init(metrics interface{}, namespace string) error
 }
 
+// InitializerV2 is an Initializer that also supports BuilderV2, middlewares, clones, instances, validation and sweepers.
+// It's a separate interface so the implementations of Initializer don't need to implement all of it.
+type InitializerV2 interface {
+	Initializer
+
+	// MustAddBuilderV2 will AddBuilderV2 and panic if an error occurs
+	MustAddBuilderV2(typ reflect.Type, builder BuilderV2)
+	// AddBuilderV2 adds a new BuilderV2 for type typ.
+	// Note that the Factory returned by the BuilderV2 should create values implementing <typ>
+	AddBuilderV2(typ reflect.Type, builder BuilderV2) error
+	// ReplaceBuilder replaces the builder for type typ, which should already have one.
+	ReplaceBuilder(typ reflect.Type, builder Builder) error
+	// ReplaceBuilderV2 replaces the builder for type typ with a BuilderV2, typ should already have a builder.
+	ReplaceBuilderV2(typ reflect.Type, builder BuilderV2) error
+	// RemoveBuilder removes the builder for type typ, which should already have one.
+	RemoveBuilder(typ reflect.Type) error
+	// Builders returns the types that have a builder, sorted by their names.
+	Builders() []reflect.Type
+
+	// Use wraps all the builders with the Middleware provided when building the metrics initialized afterwards.
+	// The first Middleware used is the outermost one.
+	Use(mw Middleware)
+	// UseV2 wraps all the builders with the MiddlewareV2 provided, like Use does.
+	UseV2(mw MiddlewareV2)
+
+	// Clone returns a new Initializer with the same registerers, options, builders and middlewares,
+	// builders and middlewares added to or removed from the clone don't affect the original one and vice versa.
+	Clone() InitializerV2
+
+	// MustInitInstance initializes an instance of the metrics or panics.
+	MustInitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels)
+
+	// InitInstance initializes the metrics in the given namespace, reporting the instance labels provided in all of them.
+	// The metrics are registered once for all the instances of the same metrics struct type, namespace and instance label names.
+	// Callbacks can't be declared in the metrics of an instance.
+	InitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) error
+
+	// Validate checks the metrics like Init does, but without registering them or setting the metric functions.
+	// The metrics are also checked not to collide with the ones gathered from the gatherers provided.
+	Validate(metrics interface{}, namespace string, against ...prometheus.Gatherer) error
+
+	// StartSweeper starts deleting the idle series of the metrics with the ttl tag every interval provided,
+	// besides deleting them when they're collected. It returns a function that stops the sweeper.
+	StartSweeper(interval time.Duration) (stop func())
+}
+
 //go:generate mockery -testonly -inpkg -case underscore -name Notifier
 
-// NewInitializer creates a new Initializer for the prometheus.Registerer provided
-func NewInitializer(registerer prometheus.Registerer) Initializer {
-	return initializer{
-		registerer: registerer,
-		builders:   make(map[reflect.Type]Builder),
+// NewInitializer creates a new Initializer for the prometheus.Registerer provided,
+// which is the DefaultRegistry, even if another one was added with that name using WithRegisterer.
+func NewInitializer(registerer prometheus.Registerer, opts ...Option) InitializerV2 {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	registerers := make(map[string]prometheus.Registerer, len(options.Registerers)+1)
+	for name, r := range options.Registerers {
+		registerers[name] = r
+	}
+	registerers[DefaultRegistry] = registerer
+	if len(options.ConstLabels) > 0 {
+		for name, r := range registerers {
+			registerers[name] = prometheus.WrapRegistererWith(options.ConstLabels, r)
+		}
+	}
+
+	in := &initializer{
+		registerers: registerers,
+		builders:    make(map[reflect.Type]BuilderV2),
+		options:     options,
+	}
+	if options.SelfMetrics {
+		in.self = newSelfMetrics(in)
+		if err := registerers[DefaultRegistry].Register(in.self); err != nil {
+			in.handleError("", registerErrorKind, fmt.Errorf("register self metrics: %s", err))
+		}
 	}
+	return in
 }
 
 type initializer struct {
-	registerer prometheus.Registerer
-	builders   map[reflect.Type]Builder
+	// admittedSeries is the number of label combinations admitted by the series budgets, accessed atomically,
+	// it's the first field so it's 64-bit aligned
+	admittedSeries int64
+
+	// registerers are the registerers of the registries where the metrics are registered, by their names
+	registerers map[string]prometheus.Registerer
+	options     Options
+
+	// eager makes the Initializer register all the metrics when initializing them, even the lazy ones
+	eager bool
+
+	mu          sync.RWMutex
+	builders    map[reflect.Type]BuilderV2
+	middlewares []MiddlewareV2
+
+	// instances are the fillers of the metrics initialized by InitInstance
+	instancesMu sync.Mutex
+	instances   map[instanceKey]filler
+
+	// trackers track the series of the metrics with the ttl tag, so they can be swept
+	trackersMu sync.Mutex
+	trackers   []*seriesTracker
+
+	// declared are the metrics initialized, in the order they were initialized
+	declaredMu sync.Mutex
+	declared   []declaredMetric
+
+	// self are the metrics about the metrics initialized, they're nil unless the SelfMetrics option is set
+	self *selfMetrics
 }
 
 // MustAddBuilder will AddBuilder and panic if an error occurs
-func (in initializer) MustAddBuilder(typ reflect.Type, builder Builder) {
+func (in *initializer) MustAddBuilder(typ reflect.Type, builder Builder) {
 	if err := in.AddBuilder(typ, builder); err != nil {
 		panic(err)
 	}
@@ -60,7 +161,23 @@ func (in initializer) MustAddBuilder(typ reflect.Type, builder Builder) {
 // AddBuilder adds a new registerer for type typ.
 // Note that the type of the first return value of Builder should be (in Java words):
 // func() interface{} implements <typ>
-func (in initializer) AddBuilder(typ reflect.Type, builder Builder) error {
+func (in *initializer) AddBuilder(typ reflect.Type, builder Builder) error {
+	return in.AddBuilderV2(typ, adaptBuilder(builder))
+}
+
+// MustAddBuilderV2 will AddBuilderV2 and panic if an error occurs
+func (in *initializer) MustAddBuilderV2(typ reflect.Type, builder BuilderV2) {
+	if err := in.AddBuilderV2(typ, builder); err != nil {
+		panic(err)
+	}
+}
+
+// AddBuilderV2 adds a new BuilderV2 for type typ.
+// Note that the Factory returned by the BuilderV2 should create values implementing <typ>
+func (in *initializer) AddBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
 	if _, ok := in.builders[typ]; ok {
 		return fmt.Errorf("type %q already has a builder", typ.Name())
 	}
@@ -68,145 +185,610 @@ func (in initializer) AddBuilder(typ reflect.Type, builder Builder) error {
 	return nil
 }
 
+// ReplaceBuilder replaces the builder for type typ, which should already have one.
+func (in *initializer) ReplaceBuilder(typ reflect.Type, builder Builder) error {
+	return in.ReplaceBuilderV2(typ, adaptBuilder(builder))
+}
+
+// ReplaceBuilderV2 replaces the builder for type typ with a BuilderV2, typ should already have a builder.
+func (in *initializer) ReplaceBuilderV2(typ reflect.Type, builder BuilderV2) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if _, ok := in.builders[typ]; !ok {
+		return fmt.Errorf("type %q doesn't have a builder", typ.Name())
+	}
+	in.builders[typ] = builder
+	return nil
+}
+
+// RemoveBuilder removes the builder for type typ, which should already have one.
+func (in *initializer) RemoveBuilder(typ reflect.Type) error {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	if _, ok := in.builders[typ]; !ok {
+		return fmt.Errorf("type %q doesn't have a builder", typ.Name())
+	}
+	delete(in.builders, typ)
+	return nil
+}
+
+// Builders returns the types that have a builder, sorted by their names.
+func (in *initializer) Builders() []reflect.Type {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	types := make([]reflect.Type, 0, len(in.builders))
+	for typ := range in.builders {
+		types = append(types, typ)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].String() < types[j].String() })
+	return types
+}
+
+// Use wraps all the builders with the Middleware provided when building the metrics initialized afterwards.
+// The first Middleware used is the outermost one.
+func (in *initializer) Use(mw Middleware) {
+	in.UseV2(adaptMiddleware(mw))
+}
+
+// UseV2 wraps all the builders with the MiddlewareV2 provided, like Use does.
+func (in *initializer) UseV2(mw MiddlewareV2) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.middlewares = append(in.middlewares, mw)
+}
+
+// Clone returns a new Initializer with the same registerers, options, builders and middlewares,
+// builders and middlewares added to or removed from the clone don't affect the original one and vice versa.
+// The instances initialized by the original one aren't shared with the clone.
+func (in *initializer) Clone() InitializerV2 {
+	clone := in.clone()
+	if in.self != nil {
+		clone.self = in.self
+		in.self.addSource(clone)
+	}
+	return clone
+}
+
+// clone returns a new initializer with the same registerers, options, builders and middlewares, but without self metrics
+func (in *initializer) clone() *initializer {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	builders := make(map[reflect.Type]BuilderV2, len(in.builders))
+	for typ, builder := range in.builders {
+		builders[typ] = builder
+	}
+
+	return &initializer{
+		registerers: in.registerers,
+		builders:    builders,
+		middlewares: append([]MiddlewareV2(nil), in.middlewares...),
+		options:     in.options,
+	}
+}
+
 // MustInit initializes the metrics or panics.
-func (in initializer) MustInit(metrics interface{}, namespace string) {
+func (in *initializer) MustInit(metrics interface{}, namespace string) {
 	if err := in.Init(metrics, namespace); err != nil {
 		panic(err)
 	}
 }
 
 // Init initializes the metrics in the given namespace.
-func (in initializer) Init(metrics interface{}, namespace string) error {
+// All the errors found are returned in an InitError.
+func (in *initializer) Init(metrics interface{}, namespace string) error {
 	metricsPtr := reflect.ValueOf(metrics)
 	if metricsPtr.Kind() != reflect.Ptr {
-		return fmt.Errorf("expected pointer to metrics struct, got %q", metricsPtr.Kind())
+		return InitError{Errors: []error{fmt.Errorf("expected pointer to metrics struct, got %q", metricsPtr.Kind())}}
 	}
 
-	return in.initMetrics(metricsPtr.Elem(), namespace)
+	group := metricsPtr.Elem()
+	fill, err := in.initMetrics(group, scope{namespaces: []string{namespace}, lazy: in.options.LazyRegistration})
+	if err != nil {
+		return err
+	}
+	fill(group, prometheus.Labels{})
+	return nil
 }
 
-func (in initializer) initMetrics(group reflect.Value, namespaces ...string) error {
+// initMetrics builds and registers the metrics of the group provided,
+// and returns the filler that sets its metric functions.
+// It initializes all the fields it can, and returns all the errors found in an InitError.
+func (in *initializer) initMetrics(group reflect.Value, s scope) (filler, error) {
 	if group.Kind() != reflect.Struct {
-		return fmt.Errorf("expected group %s to be a struct, got %q", group.Type().Name(), group.Kind())
+		return nil, InitError{Errors: []error{fmt.Errorf("expected group %s to be a struct, got %q", group.Type().Name(), group.Kind())}}
+	}
+	groupType := group.Type()
+
+	var errs initErrors
+	groupLabels, err := findGroupLabels(groupType, s)
+	errs.add(err)
+	if groupLabels != nil {
+		labelsField := groupType.Field(groupLabels.fieldIndex)
+		labelsPath := s.withPath(labelsField.Name).fieldPath()
+		errs.add(in.checkTagKeys(labelsPath, labelsField.Tag))
+		errs.add(in.checkLabelTagKeys(labelsPath, groupLabels.funcType.In(0)))
+
+		labeled, err := s.withLabels(groupLabels.labels)
+		errs.add(err)
+		s = labeled
 	}
 
-	for i := 0; i < group.Type().NumField(); i++ {
+	fillers := make([]filler, groupType.NumField())
+	metrics := make(map[string]builtMetric)
+	var companions []int
+	for i := 0; i < groupType.NumField(); i++ {
 		field := group.Field(i)
-		fieldType := group.Type().Field(i)
-
-		if fieldType.Type.Kind() == reflect.Func {
-			if err := in.initMetricFunc(field, fieldType, namespaces...); err != nil {
-				return err
+		fieldType := groupType.Field(i)
+		fieldScope := s.withPath(fieldType.Name)
+
+		if groupLabels != nil && i == groupLabels.fieldIndex {
+			continue
+		} else if isCompanion(fieldType) {
+			// Companions are initialized once all the metrics of the group are built
+			companions = append(companions, i)
+		} else if isCallback(fieldType.Type) {
+			if s.instance {
+				errs.add(fmt.Errorf("field %s: callbacks can't be declared in metric instances, since the instances share the registered metrics", fieldScope.fieldPath()))
+				continue
+			}
+			if len(s.labelNames) > 0 {
+				errs.add(fmt.Errorf("field %s: callbacks can't be declared in groups with labels", fieldScope.fieldPath()))
+				continue
 			}
+			errs.add(in.initCallback(field, fieldType, fieldScope))
+		} else if fieldType.Type.Kind() == reflect.Func {
+			var metric builtMetric
+			if fillers[i], metric, err = in.initMetricFunc(field, fieldType, fieldScope); err == nil {
+				metrics[fieldType.Name] = metric
+			}
+			errs.add(err)
 		} else if fieldType.Type.Kind() == reflect.Struct {
+			errs.add(in.checkTagKeys(fieldScope.fieldPath(), fieldType.Tag, groupTagKeys))
 			namespace, ok := fieldType.Tag.Lookup("namespace")
 			if !ok {
-				return fmt.Errorf("field %s does not have the namespace tag defined", fieldType.Name)
+				errs.add(ErrMissingTag{Field: fieldScope.fieldPath(), Tag: "namespace"})
+				continue
 			}
-			if err := in.initMetrics(field, append(namespaces, namespace)...); err != nil {
-				return err
+			if fieldScope, err = fieldScope.withLazyTag(fieldType.Tag); err != nil {
+				errs.add(err)
+				continue
+			}
+			if fieldScope, err = fieldScope.withRegistryTag(fieldType.Tag); err != nil {
+				errs.add(err)
+				continue
 			}
+			fillers[i], err = in.initMetrics(field, fieldScope.withNamespace(namespace))
+			errs.add(err)
 		} else {
-			return fmt.Errorf("metrics are expected to contain only funcs or nested metric structs, but %s is %s", fieldType.Name, fieldType.Type.Kind())
+			errs.add(fmt.Errorf("metrics are expected to contain only funcs, callbacks or nested metric structs, but %s is %s", fieldScope.fieldPath(), fieldType.Type.Kind()))
 		}
 	}
-	return nil
+	for _, i := range companions {
+		fillers[i], err = in.initCompanion(group.Field(i), groupType.Field(i), s.withPath(groupType.Field(i).Name), groupType, metrics)
+		errs.add(err)
+	}
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+
+	var fill filler
+	fill = func(group reflect.Value, bound prometheus.Labels) {
+		for i, f := range fillers {
+			if f != nil {
+				f(group.Field(i), bound)
+			}
+		}
+		if groupLabels != nil {
+			groupLabels.fill(group, bound, fill)
+		}
+	}
+
+	if groupLabels == nil {
+		return fill, nil
+	}
+	return func(group reflect.Value, bound prometheus.Labels) {
+		if defaults, ok := groupLabels.defaults(); ok {
+			bound = mergeLabels(bound, defaults)
+		}
+		fill(group, bound)
+	}, nil
 }
 
-func (in initializer) initMetricFunc(field reflect.Value, structField reflect.StructField, namespaces ...string) (err error) {
-	namespace := strings.Join(namespaces, "_")
+// initMetricFunc builds and registers the metric of the field provided, declared in the scope provided,
+// whose path should already include the field.
+func (in *initializer) initMetricFunc(field reflect.Value, structField reflect.StructField, s scope) (fill filler, metric builtMetric, err error) {
 	fieldType := field.Type()
+	path := s.fieldPath()
 
 	if !field.CanSet() {
-		return fmt.Errorf("field %q needs be exported", structField.Name)
+		return nil, builtMetric{}, fmt.Errorf("field %s needs be exported", path)
 	}
 
 	tag := structField.Tag
 	name, ok := tag.Lookup("name")
 	if !ok {
-		return fmt.Errorf("name tag for %s missing", structField.Name)
+		return nil, builtMetric{}, ErrMissingTag{Field: path, Tag: "name"}
 	}
-	help, ok := tag.Lookup("help")
-	if !ok {
-		return fmt.Errorf("help tag for %s missing", structField.Name)
+	if s, err = s.withLazyTag(tag); err != nil {
+		return nil, builtMetric{}, err
+	}
+	if s, err = s.withRegistryTag(tag); err != nil {
+		return nil, builtMetric{}, err
 	}
 
 	// Validate the input of the metric function, it should have zero or one arguments
 	// If it has one argument, it should be a struct correctly tagged with label names
 	// If there are no input arguments, this metric will not have labels registered
-	var labelIndexes = make(map[label][]int)
+	var labels []label
 	if fieldType.NumIn() > 1 {
-		return fmt.Errorf("field %s: expected 1 in arg, got %d", structField.Name, fieldType.NumIn())
+		return nil, builtMetric{}, fmt.Errorf("field %s: expected 1 in arg, got %d", path, fieldType.NumIn())
 	} else if fieldType.NumIn() == 1 {
 		inArg := fieldType.In(0)
-		err := findLabelIndexes(inArg, labelIndexes)
-		if err != nil {
-			return fmt.Errorf("build labels for field %q: %s", structField.Name, err)
+		if labels, err = findLabels(path, inArg); err != nil {
+			return nil, builtMetric{}, err
+		}
+		if err := in.checkLabelTagKeys(path, inArg); err != nil {
+			return nil, builtMetric{}, err
 		}
 	}
-	labelNames := make([]string, 0, len(labelIndexes))
-	for label := range labelIndexes {
-		labelNames = append(labelNames, label.name)
+	if s, err = s.withLabels(labels); err != nil {
+		return nil, builtMetric{}, err
 	}
 
 	// Validate the output and register the correct metric type based on the output type
 	if fieldType.NumOut() != 1 {
-		return fmt.Errorf("field %s: expected 1 return arg, got %d", structField.Name, fieldType.NumOut())
+		return nil, builtMetric{}, fmt.Errorf("field %s: expected 1 return arg, got %d", path, fieldType.NumOut())
 	}
 	returnArg := fieldType.Out(0)
 
-	builder, ok := in.builders[returnArg]
-	if !ok {
-		return fmt.Errorf("field %s: no builder found for type %q", structField.Name, returnArg.Name())
+	builder, err := in.resolveBuilder(returnArg, tag)
+	if err != nil {
+		return nil, builtMetric{}, fmt.Errorf("field %s: %s", path, err)
 	}
 
-	// metric's type is:
-	//   func(map[string]string) interface{} implements <returnArg>
-	// but there's no use case for generics in Go
-	metric, collector, err := builder(name, help, namespace, labelNames, tag)
+	if builder != nil {
+		metric, err = in.buildMetric(builder, structField, name, s)
+	} else if returnArg.Kind() == reflect.Struct {
+		if err = in.checkTagKeys(path, tag, metricTagKeys); err == nil {
+			metric, err = in.buildBundle(returnArg, name, s)
+		}
+	} else {
+		err = ErrNoBuilder{Field: path, Type: returnArg}
+	}
 	if err != nil {
-		return fmt.Errorf("build metric %q: %s", name, err)
+		return nil, builtMetric{}, err
 	}
 
-	err = in.registerer.Register(collector)
+	fqName := prometheus.BuildFQName(strings.Join(s.namespaces, "_"), "", name)
+	budget, err := in.newSeriesBudget(path, fqName, tag, metric)
 	if err != nil {
-		return fmt.Errorf("register metric %q: %s", name, err)
+		return nil, builtMetric{}, err
 	}
 
-	metricFunc := func(args []reflect.Value) []reflect.Value {
-		labels := make(prometheus.Labels, len(labelIndexes))
-		for label, index := range labelIndexes {
-			value := args[0].FieldByIndex(index)
+	// All the dropped label combinations share a single series of the no-op metric, so they don't pile up
+	var droppedOnce sync.Once
+	var dropped interface{}
+	overflow := make(prometheus.Labels, len(s.labelNames))
+	for _, name := range s.labelNames {
+		overflow[name] = OverflowLabelValue
+	}
+	drop := func() interface{} {
+		droppedOnce.Do(func() { dropped = metric.noOp(overflow) })
+		if dropped != nil {
+			return dropped
+		}
+		return metric.factory(overflow)
+	}
+	var unscopedOnce sync.Once
+
+	sampler := in.self.sampler(fqName)
+	return func(field reflect.Value, bound prometheus.Labels) {
+		metricFunc := func(args []reflect.Value) []reflect.Value {
+			if sampler != nil {
+				if stop := sampler.start(); stop != nil {
+					defer stop()
+				}
+			}
 
-			if label.hasDefaultValue && value.Interface() == label.zeroTypeValueInterface {
-				value = label.defaultValue
+			var values prometheus.Labels
+			if len(args) == 1 {
+				values = labelsFromValue(labels, args[0])
+			} else {
+				values = make(prometheus.Labels, len(bound))
+			}
+			for name, value := range bound {
+				values[name] = value
 			}
 
-			switch k := label.kind; k {
-			case reflect.Bool:
-				labels[label.name] = strconv.FormatBool(value.Bool())
-			case reflect.String:
-				labels[label.name] = value.String()
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				labels[label.name] = strconv.FormatInt(value.Int(), 10)
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				labels[label.name] = strconv.FormatUint(value.Uint(), 10)
-			default:
-				// Should not happen since we've already checked this in the findLabelIndexes function
-				panic(fmt.Errorf("field %s has unsupported kind %v", label.name, label.kind))
+			// The labels of the groups that weren't scoped with their labels func and have no defaults are missing
+			if len(values) < len(overflow) {
+				unscopedOnce.Do(func() {
+					in.handleError(fqName, unscopedErrorKind, fmt.Errorf("field %s: metric %s used without scoping its groups with their labels funcs, got labels %v", path, fqName, values))
+				})
+				return []reflect.Value{reflect.ValueOf(drop()).Convert(returnArg)}
 			}
+
+			// The budget is enforced before the builder creates the series
+			if budget != nil {
+				switch budget.admit(values, args) {
+				case dropSeries:
+					return []reflect.Value{reflect.ValueOf(drop()).Convert(returnArg)}
+				case overflowSeries:
+					values = overflowValues(values)
+				}
+			}
+			return []reflect.Value{reflect.ValueOf(metric.factory(values)).Convert(returnArg)}
 		}
-		return []reflect.Value{reflect.ValueOf(metric(labels)).Convert(returnArg)}
+
+		field.Set(reflect.MakeFunc(fieldType, metricFunc))
+	}, metric, nil
+}
+
+// buildMetric builds the metric for the given field using the builder provided, and registers it.
+// The name is provided separately from the field's tag as bundle fields are prefixed with their bundle's name.
+// The path of the scope provided should already include the field.
+func (in *initializer) buildMetric(builder BuilderV2, structField reflect.StructField, name string, s scope) (builtMetric, error) {
+	path := s.fieldPath()
+	tag := structField.Tag
+	help, ok := tag.Lookup("help")
+	if !ok {
+		return builtMetric{}, ErrMissingTag{Field: path, Tag: "help"}
+	}
+	namespace := strings.Join(s.namespaces, "_")
+	metricType := structField.Type
+	if metricType.Kind() == reflect.Func {
+		metricType = metricType.Out(0)
 	}
 
-	field.Set(reflect.MakeFunc(fieldType, metricFunc))
-	return nil
+	if in.options.StrictNaming {
+		fqName := prometheus.BuildFQName(namespace, "", name)
+		if err := checkNaming(path, fqName, kindOf(metricType), in.withConstLabelNames(s.labelNames), tag.Get("unit")); err != nil {
+			return builtMetric{}, err
+		}
+	}
+	registerers, err := in.registerersFor(s)
+	if err != nil {
+		return builtMetric{}, err
+	}
+	ttl, err := ttlFromTag(path, tag)
+	if err != nil {
+		return builtMetric{}, err
+	}
+	if ttl > 0 || in.self != nil {
+		// The self metrics count the series tracked
+		builder = in.tracking(builder, ttl)
+	}
+
+	ctx := BuildContext{
+		Name:        name,
+		Help:        help,
+		Namespace:   namespace,
+		FieldPath:   path,
+		LabelNames:  s.labelNames,
+		LabelTypes:  s.labelTypes,
+		ConstLabels: in.options.ConstLabels,
+		Tag:         tag,
+		Registerer:  registerers[0],
+		Options:     in.options,
+	}
+	result, err := in.build(builder, ctx)
+	if err != nil {
+		return builtMetric{}, err
+	}
+	var metric builtMetric
+	if s.lazy && !in.eager {
+		metric = in.lazyMetric(result, ctx, metricType, registerers)
+	} else {
+		result, err := in.register(result, ctx, metricType, registerers)
+		if err != nil {
+			return builtMetric{}, err
+		}
+		metric = builtMetric{
+			factory: result.Factory,
+			noOp: func(labels prometheus.Labels) interface{} {
+				if result.NoOp == nil {
+					return nil
+				}
+				return result.NoOp(labels)
+			},
+			collectors: func() []prometheus.Collector { return []prometheus.Collector{result.Collector} },
+		}
+	}
+
+	in.declare(declaredMetric{
+		fieldPath:  path,
+		name:       prometheus.BuildFQName(namespace, "", name),
+		typ:        metricType.String(),
+		help:       help,
+		labelNames: s.labelNames,
+		registries: s.registries,
+		lazy:       s.lazy && !in.eager,
+		collectors: metric.collectors,
+		series:     countTracked(metric.collectors),
+	})
+	return metric, nil
+}
+
+// build builds the metric described by the BuildContext provided using the builder provided,
+// checking the tag keys the builder reads.
+func (in *initializer) build(builder BuilderV2, ctx BuildContext) (BuildResult, error) {
+	result, err := in.wrap(builder)(ctx)
+	var errs initErrors
+	if result.TagKeys != nil {
+		errs.add(in.checkTagKeys(ctx.FieldPath, ctx.Tag, metricTagKeys, result.TagKeys))
+	}
+	if err != nil {
+		errs.add(fmt.Errorf("field %s: build metric %q: %s", ctx.FieldPath, ctx.Name, err))
+	}
+	if err := errs.err(); err != nil {
+		return BuildResult{}, err
+	}
+	return result, nil
+}
+
+// register registers the metric of the type provided built with the result provided with the registerers provided,
+// and returns the result of the metric to use, which is the one already registered if it's reused.
+// The metric built is returned even if it can't be registered, along with the error.
+func (in *initializer) register(result BuildResult, ctx BuildContext, metricType reflect.Type, registerers []prometheus.Registerer) (BuildResult, error) {
+	collector := result.Collector
+	if in.options.ReuseCollectors {
+		collector = &reusableCollector{Collector: result.Collector, factory: result.Factory, metricType: metricType}
+	}
+	registered, reused := false, false
+	for _, registerer := range registerers {
+		err := registerer.Register(collector)
+		switch {
+		case err == nil:
+			registered = true
+		case isAlreadyRegistered(err, collector):
+			// Registered in the same registry through another registerer, or reused
+		case in.options.ReuseCollectors && !registered && !reused:
+			// The collector can only be reused if it's not registered yet, otherwise registries would have different collectors
+			if result, collector, err = in.reuse(result, ctx, metricType, err); err != nil {
+				return result, err
+			}
+			reused = true
+		default:
+			return result, fmt.Errorf("field %s: register metric %q: %s", ctx.FieldPath, ctx.Name, err)
+		}
+	}
+	return result, nil
+}
+
+// buildBundle builds one metric for each one of the fields of the bundle struct provided, sharing the same labels.
+// The names of the metrics in the bundle are prefixed by the name of the field returning the bundle, if it's not empty.
+// The path of the scope provided should already include the field returning the bundle.
+// The factory of the metric it returns returns a populated bundle as an interface{}
+func (in *initializer) buildBundle(bundleType reflect.Type, prefix string, s scope) (builtMetric, error) {
+	var errs initErrors
+	metrics := make([]builtMetric, bundleType.NumField())
+	for i := 0; i < bundleType.NumField(); i++ {
+		bundleField := bundleType.Field(i)
+		fieldScope := s.withPath(bundleField.Name)
+		path := fieldScope.fieldPath()
+		if bundleField.PkgPath != "" {
+			errs.add(fmt.Errorf("bundle field %s needs be exported", path))
+			continue
+		}
+
+		name, ok := bundleField.Tag.Lookup("name")
+		if !ok {
+			errs.add(ErrMissingTag{Field: path, Tag: "name"})
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+
+		builder, err := in.resolveBuilder(bundleField.Type, bundleField.Tag)
+		if err != nil {
+			errs.add(fmt.Errorf("bundle field %s: %s", path, err))
+			continue
+		}
+		if builder == nil {
+			errs.add(ErrNoBuilder{Field: path, Type: bundleField.Type})
+			continue
+		}
+
+		if fieldScope, err = fieldScope.withLazyTag(bundleField.Tag); err != nil {
+			errs.add(err)
+			continue
+		}
+		if fieldScope, err = fieldScope.withRegistryTag(bundleField.Tag); err != nil {
+			errs.add(err)
+			continue
+		}
+		metrics[i], err = in.buildMetric(builder, bundleField, name, fieldScope)
+		errs.add(err)
+	}
+	if err := errs.err(); err != nil {
+		return builtMetric{}, err
+	}
+
+	return builtMetric{
+		factory: func(labels prometheus.Labels) interface{} {
+			bundle := reflect.New(bundleType).Elem()
+			for i, metric := range metrics {
+				bundleField := bundle.Field(i)
+				bundleField.Set(reflect.ValueOf(metric.factory(labels)).Convert(bundleField.Type()))
+			}
+			return bundle.Interface()
+		},
+		noOp: func(labels prometheus.Labels) interface{} {
+			bundle := reflect.New(bundleType).Elem()
+			for i, metric := range metrics {
+				noOp := metric.noOp(labels)
+				if noOp == nil {
+					return nil
+				}
+				bundleField := bundle.Field(i)
+				bundleField.Set(reflect.ValueOf(noOp).Convert(bundleField.Type()))
+			}
+			return bundle.Interface()
+		},
+		collectors: func() []prometheus.Collector {
+			var collectors []prometheus.Collector
+			for _, metric := range metrics {
+				collectors = append(collectors, metric.collectors()...)
+			}
+			return collectors
+		},
+	}, nil
+}
+
+// builtMetric is a metric built by the Initializer
+type builtMetric struct {
+	// factory creates the metric reporter for given label values
+	factory func(prometheus.Labels) interface{}
+	// noOp creates metric reporters like factory does, but their values are not collected,
+	// it returns nil if the builder of the metric can't provide them
+	noOp func(prometheus.Labels) interface{}
+	// collectors returns the collectors registered for the metric, lazy metrics have none until they're first used
+	collectors func() []prometheus.Collector
+}
+
+// labelsFromValue builds the prometheus.Labels from the values of a labels struct
+func labelsFromValue(labels []label, labelsValue reflect.Value) prometheus.Labels {
+	values := make(prometheus.Labels, len(labels))
+	for _, label := range labels {
+		value := labelsValue.FieldByIndex(label.index)
+
+		if label.hasDefaultValue && value.Interface() == label.zeroTypeValueInterface {
+			value = label.defaultValue
+		}
+
+		switch k := label.kind; k {
+		case reflect.Bool:
+			values[label.name] = strconv.FormatBool(value.Bool())
+		case reflect.String:
+			values[label.name] = value.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			values[label.name] = strconv.FormatInt(value.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			values[label.name] = strconv.FormatUint(value.Uint(), 10)
+		default:
+			// Should not happen since we've already checked this in the findLabels function
+			panic(fmt.Errorf("field %s has unsupported kind %v", label.name, label.kind))
+		}
+	}
+	return values
 }
 
 type label struct {
 	kind reflect.Kind
+	typ  reflect.Type
 	name string
 
+	// index is the index sequence of the field in the labels struct, see reflect.Value.FieldByIndex
+	index []int
+	// fieldPath is the path of the field declaring the label, like Requests.Total.Code
+	fieldPath string
+
 	// hasDefaultValue indicates that zero values should be replaced by default values
 	hasDefaultValue bool
 	// zeroTypeValueInterface is the interface value of the zero-value for this field's type
@@ -215,46 +797,111 @@ type label struct {
 	defaultValue reflect.Value
 }
 
-func findLabelIndexes(typ reflect.Type, indexes map[label][]int, current ...int) error {
+// labelNamesAndTypes returns the names of the labels provided and the types of their fields, in the same order
+func labelNamesAndTypes(labels []label) ([]string, []reflect.Type) {
+	names := make([]string, len(labels))
+	types := make([]reflect.Type, len(labels))
+	for i, label := range labels {
+		names[i] = label.name
+		types[i] = label.typ
+	}
+	return names, types
+}
+
+// findLabels finds the labels of the labels struct type provided, used by the metric in the path provided.
+// The labels are returned in the order their fields are declared, including the ones in embedded structs.
+func findLabels(path string, typ reflect.Type) ([]label, error) {
 	if typ.Kind() != reflect.Struct {
-		return fmt.Errorf("expected to get a Struct for %s, got %s", typ.Name(), typ.Kind())
+		return nil, fmt.Errorf("field %s: expected labels to be a struct, got %s", path, typ.Kind())
+	}
+
+	var labels []label
+	if err := appendLabels(&labels, path, typ, path, nil); err != nil {
+		return nil, err
 	}
+	return labels, nil
+}
 
+// appendLabels appends to the labels provided the labels of the struct type provided,
+// whose fields are in the path provided and have the index sequence provided
+func appendLabels(labels *[]label, path string, typ reflect.Type, fieldPath string, index []int) error {
 	for i := 0; i < typ.NumField(); i++ {
 		f := typ.Field(i)
+		labelFieldPath := fieldPath + "." + f.Name
+		labelIndex := append(append(make([]int, 0, len(index)+1), index...), i)
+
 		if f.Type.Kind() == reflect.Struct {
-			if err := findLabelIndexes(f.Type, indexes, append(current, i)...); err != nil {
+			if err := appendLabels(labels, path, f.Type, labelFieldPath, labelIndex); err != nil {
 				return err
 			}
-		} else {
-			labelTag, ok := f.Tag.Lookup("label")
-			if !ok {
-				return fmt.Errorf("field %s does not have the label tag", f.Name)
-			}
+			continue
+		}
 
-			switch k := f.Type.Kind(); k {
-			case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
-				reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-			default:
-				return fmt.Errorf("field %s has unsupported type %v", labelTag, k)
-			}
+		labelTag, ok := f.Tag.Lookup("label")
+		if !ok {
+			return ErrMissingTag{Field: labelFieldPath, Tag: "label"}
+		}
 
-			label := label{
-				kind: f.Type.Kind(),
-				name: labelTag,
-			}
+		switch k := f.Type.Kind(); k {
+		case reflect.String, reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		default:
+			return ErrUnsupportedLabelKind{Field: path, Label: labelTag, Kind: k}
+		}
 
-			if emptyTag, ok := f.Tag.Lookup("default"); ok {
-				label.hasDefaultValue = true
-				label.defaultValue = reflect.ValueOf(emptyTag)
-				label.zeroTypeValueInterface = reflect.Zero(f.Type).Interface()
+		for _, declared := range *labels {
+			if declared.name == labelTag {
+				return ErrDuplicateLabel{Field: path, Label: labelTag, LabelFields: []string{declared.fieldPath, labelFieldPath}}
 			}
+		}
 
-			if _, ok := indexes[label]; ok {
-				return fmt.Errorf("label %+v can't be registered twice", label)
+		label := label{
+			kind:      f.Type.Kind(),
+			typ:       f.Type,
+			name:      labelTag,
+			index:     labelIndex,
+			fieldPath: labelFieldPath,
+		}
+
+		if defaultTag, ok := f.Tag.Lookup("default"); ok {
+			defaultValue, err := parseDefaultValue(f.Type, defaultTag)
+			if err != nil {
+				return fmt.Errorf("field %s: default tag should be a %s, got %q: %s", labelFieldPath, f.Type.Kind(), defaultTag, err)
 			}
-			indexes[label] = append(current, i)
+			label.hasDefaultValue = true
+			label.defaultValue = defaultValue
+			label.zeroTypeValueInterface = reflect.Zero(f.Type).Interface()
 		}
+
+		*labels = append(*labels, label)
 	}
 	return nil
 }
+
+// parseDefaultValue parses the default tag of a label field into a value of the field type
+func parseDefaultValue(typ reflect.Type, defaultTag string) (reflect.Value, error) {
+	value := reflect.New(typ).Elem()
+	switch typ.Kind() {
+	case reflect.Bool:
+		b, err := strconv.ParseBool(defaultTag)
+		if err != nil {
+			return value, err
+		}
+		value.SetBool(b)
+	case reflect.String:
+		value.SetString(defaultTag)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(defaultTag, 10, typ.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(defaultTag, 10, typ.Bits())
+		if err != nil {
+			return value, err
+		}
+		value.SetUint(u)
+	}
+	return value, nil
+}
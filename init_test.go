@@ -1,11 +1,17 @@
 package gotoprom
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/cabify/gotoprom/prometheusvanilla"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestInitializer_MustAddBuilder(t *testing.T) {
@@ -39,6 +45,113 @@ func TestInitializer_AddBuilder(t *testing.T) {
 	})
 }
 
+func TestInitializer_ReplaceBuilder(t *testing.T) {
+	t.Run("replaces builder", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		replaced := false
+		err := initializer.ReplaceBuilder(prometheusvanilla.GaugeType, func(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+			replaced = true
+			return prometheusvanilla.BuildGauge(name, help, namespace, labelNames, tag)
+		})
+		require.NoError(t, err)
+
+		err = initializer.Init(&struct {
+			Metric func() prometheus.Gauge `name:"gauge" help:"help"`
+		}{}, "namespace")
+		assert.NoError(t, err)
+		assert.True(t, replaced)
+	})
+	t.Run("fails when there's no builder", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		err := initializer.ReplaceBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		assert.Error(t, err)
+		err = initializer.ReplaceBuilderV2(prometheusvanilla.GaugeType, adaptBuilder(prometheusvanilla.BuildGauge))
+		assert.Error(t, err)
+	})
+}
+
+func TestInitializer_RemoveBuilder(t *testing.T) {
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+	err := initializer.RemoveBuilder(prometheusvanilla.GaugeType)
+	require.NoError(t, err)
+	assert.Empty(t, initializer.Builders())
+
+	err = initializer.RemoveBuilder(prometheusvanilla.GaugeType)
+	assert.Error(t, err, "fails when there's no builder")
+}
+
+func TestInitializer_Builders(t *testing.T) {
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+	initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+	assert.Equal(t, []reflect.Type{
+		prometheusvanilla.CounterType,
+		prometheusvanilla.GaugeType,
+		prometheusvanilla.SummaryType,
+	}, initializer.Builders())
+}
+
+func TestInitializer_Clone(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	original := NewInitializer(registry, WithConstLabels(prometheus.Labels{"env": "test"}))
+	original.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+	clone := original.Clone()
+	clone.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+	require.NoError(t, original.RemoveBuilder(prometheusvanilla.GaugeType))
+
+	assert.Empty(t, original.Builders())
+	assert.Equal(t, []reflect.Type{prometheusvanilla.CounterType, prometheusvanilla.GaugeType}, clone.Builders())
+
+	var metrics struct {
+		Gauge func() prometheus.Gauge `name:"gauge" help:"Gauge built by the clone"`
+	}
+	require.NoError(t, clone.Init(&metrics, "test"))
+	metrics.Gauge().Set(1)
+
+	expected := `
+# HELP test_gauge Gauge built by the clone
+# TYPE test_gauge gauge
+test_gauge{env="test"} 1
+`
+	err := testutil.GatherAndCompare(registry, strings.NewReader(expected))
+	assert.NoError(t, err)
+}
+
+func TestInitializer_Concurrency(t *testing.T) {
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(3)
+		go func(i int) {
+			defer wg.Done()
+			var metrics struct {
+				Gauge func() prometheus.Gauge `name:"gauge" help:"Gauge"`
+			}
+			assert.NoError(t, initializer.Init(&metrics, fmt.Sprintf("test%d", i)))
+		}(i)
+		go func() {
+			defer wg.Done()
+			_ = initializer.AddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+			_ = initializer.RemoveBuilder(prometheusvanilla.CounterType)
+		}()
+		go func() {
+			defer wg.Done()
+			initializer.Clone().MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+			_ = initializer.Builders()
+		}()
+	}
+	wg.Wait()
+}
+
 func TestInitializer_MustInit(t *testing.T) {
 	t.Run("happy case", func(t *testing.T) {
 		initializer := NewInitializer(prometheus.NewRegistry())
@@ -67,6 +180,18 @@ func TestInitializer_Init(t *testing.T) {
 	type someLabelsWithoutLabelTag struct {
 		LabelWithoutLabelTag string
 	}
+	type bundleWithoutName struct {
+		Gauge prometheus.Gauge `help:"name is missing"`
+	}
+	type bundleWithoutHelp struct {
+		Gauge prometheus.Gauge `name:"gauge"`
+	}
+	type bundleWithUnexportedField struct {
+		gauge prometheus.Gauge `name:"gauge" help:"can't be set"`
+	}
+	type bundleWithoutBuilder struct {
+		Counter prometheus.Counter `name:"counter" help:"Counter is not registered"`
+	}
 	t.Run("fails", func(t *testing.T) {
 		for _, tc := range []struct {
 			desc    string
@@ -154,6 +279,30 @@ func TestInitializer_Init(t *testing.T) {
 					Foo func(someLabelsWithoutLabelTag) prometheus.Gauge `name:"nolabeltag" help:"This labels are missing the label tag"`
 				}{},
 			},
+			{
+				desc: "bundle field without name",
+				metrics: &struct {
+					Foo func(someLabels) bundleWithoutName `name:"bundle"`
+				}{},
+			},
+			{
+				desc: "bundle field without help",
+				metrics: &struct {
+					Foo func(someLabels) bundleWithoutHelp `name:"bundle"`
+				}{},
+			},
+			{
+				desc: "bundle field not exported",
+				metrics: &struct {
+					Foo func(someLabels) bundleWithUnexportedField `name:"bundle"`
+				}{},
+			},
+			{
+				desc: "bundle field without builder",
+				metrics: &struct {
+					Foo func(someLabels) bundleWithoutBuilder `name:"bundle"`
+				}{},
+			},
 			{
 				desc: "metric registration fails",
 				metrics: &struct {
@@ -173,3 +322,65 @@ func TestInitializer_Init(t *testing.T) {
 		}
 	})
 }
+
+func TestInitializer_Init_Bundles(t *testing.T) {
+	type labels struct {
+		Endpoint string `label:"endpoint"`
+	}
+	type red struct {
+		Requests prometheus.Counter   `name:"requests_total" help:"Requests served"`
+		Errors   prometheus.Counter   `name:"errors_total" help:"Requests failed"`
+		Duration prometheus.Histogram `name:"duration_seconds" help:"Duration of the requests" buckets:"1"`
+	}
+
+	registry := prometheus.NewRegistry()
+	initializer := NewInitializer(registry)
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+	initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+
+	var metrics struct {
+		HTTP     func(labels) red `name:"http"`
+		Unnamed  func() red       `name:""`
+		Disjoint struct {
+			GRPC func(labels) red `name:"grpc"`
+		} `namespace:"disjoint"`
+	}
+	err := initializer.Init(&metrics, "test")
+	require.NoError(t, err)
+
+	http := metrics.HTTP(labels{Endpoint: "/"})
+	http.Requests.Inc()
+	http.Errors.Add(2)
+	http.Duration.Observe(0.5)
+	metrics.Unnamed().Requests.Inc()
+	metrics.Disjoint.GRPC(labels{Endpoint: "Get"}).Errors.Inc()
+
+	expected := `
+# HELP test_disjoint_grpc_errors_total Requests failed
+# TYPE test_disjoint_grpc_errors_total counter
+test_disjoint_grpc_errors_total{endpoint="Get"} 1
+# HELP test_http_duration_seconds Duration of the requests
+# TYPE test_http_duration_seconds histogram
+test_http_duration_seconds_bucket{endpoint="/",le="1"} 1
+test_http_duration_seconds_bucket{endpoint="/",le="+Inf"} 1
+test_http_duration_seconds_sum{endpoint="/"} 0.5
+test_http_duration_seconds_count{endpoint="/"} 1
+# HELP test_http_errors_total Requests failed
+# TYPE test_http_errors_total counter
+test_http_errors_total{endpoint="/"} 2
+# HELP test_http_requests_total Requests served
+# TYPE test_http_requests_total counter
+test_http_requests_total{endpoint="/"} 1
+# HELP test_requests_total Requests served
+# TYPE test_requests_total counter
+test_requests_total 1
+`
+	err = testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"test_requests_total",
+		"test_disjoint_grpc_errors_total",
+		"test_http_duration_seconds",
+		"test_http_errors_total",
+		"test_http_requests_total",
+	)
+	assert.NoError(t, err)
+}
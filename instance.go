@@ -0,0 +1,86 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// instanceKey identifies the metrics shared by the instances of a metrics struct type
+type instanceKey struct {
+	typ        reflect.Type
+	namespace  string
+	labelNames string
+}
+
+// MustInitInstance initializes an instance of the metrics or panics.
+func (in *initializer) MustInitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) {
+	if err := in.InitInstance(metrics, namespace, instanceLabels); err != nil {
+		panic(err)
+	}
+}
+
+// InitInstance initializes the metrics in the given namespace, reporting the instance labels provided in all of them.
+// The metrics are registered the first time an instance of the metrics struct type is initialized with the same
+// namespace and instance label names, the following instances share them, like the copies returned by group labels funcs.
+// Callbacks can't be declared in the metrics of an instance, since their registered metrics would be shared too.
+// All the errors found are returned in an InitError.
+func (in *initializer) InitInstance(metrics interface{}, namespace string, instanceLabels prometheus.Labels) error {
+	metricsPtr := reflect.ValueOf(metrics)
+	if metricsPtr.Kind() != reflect.Ptr {
+		return InitError{Errors: []error{fmt.Errorf("expected pointer to metrics struct, got %q", metricsPtr.Kind())}}
+	}
+	group := metricsPtr.Elem()
+
+	labelNames := make([]string, 0, len(instanceLabels))
+	for name := range instanceLabels {
+		labelNames = append(labelNames, name)
+	}
+	sort.Strings(labelNames)
+	key := instanceKey{typ: group.Type(), namespace: namespace, labelNames: strings.Join(labelNames, ",")}
+
+	fill, err := in.instanceFiller(key, group, labelNames)
+	if err != nil {
+		return err
+	}
+	fill(group, mergeLabels(instanceLabels))
+	return nil
+}
+
+// instanceFiller returns the filler of the instances identified by the key provided,
+// initializing their metrics the first time, the group provided is only used to initialize them.
+func (in *initializer) instanceFiller(key instanceKey, group reflect.Value, labelNames []string) (filler, error) {
+	in.instancesMu.Lock()
+	defer in.instancesMu.Unlock()
+
+	if fill, ok := in.instances[key]; ok {
+		return fill, nil
+	}
+
+	labels := make([]label, len(labelNames))
+	for i, name := range labelNames {
+		labels[i] = label{
+			kind:      reflect.String,
+			typ:       reflect.TypeOf(""),
+			name:      name,
+			fieldPath: "instance label " + name,
+		}
+	}
+	s, err := scope{namespaces: []string{key.namespace}, instance: true, lazy: in.options.LazyRegistration}.withLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	fill, err := in.initMetrics(group, s)
+	if err != nil {
+		return nil, err
+	}
+	if in.instances == nil {
+		in.instances = make(map[instanceKey]filler)
+	}
+	in.instances[key] = fill
+	return fill, nil
+}
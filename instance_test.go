@@ -0,0 +1,130 @@
+package gotoprom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_InitInstance(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry) InitializerV2 {
+		initializer := NewInitializer(registry)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		return initializer
+	}
+
+	type labels struct {
+		Code int `label:"code"`
+	}
+	type metrics struct {
+		Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+		Pool     struct {
+			Conns func() prometheus.Counter `name:"conns_total" help:"Connections"`
+		} `namespace:"pool"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry)
+
+		var acme, globex metrics
+		require.NoError(t, initializer.InitInstance(&acme, "test", prometheus.Labels{"tenant": "acme", "pool": "main"}))
+		require.NoError(t, initializer.InitInstance(&globex, "test", prometheus.Labels{"pool": "main", "tenant": "globex"}))
+
+		acme.Requests(labels{Code: 200}).Inc()
+		globex.Requests(labels{Code: 200}).Add(2)
+		acme.Pool.Conns()
+		globex.Pool.Conns().Inc()
+
+		expected := `
+# HELP test_pool_conns_total Connections
+# TYPE test_pool_conns_total counter
+test_pool_conns_total{pool="main",tenant="acme"} 0
+test_pool_conns_total{pool="main",tenant="globex"} 1
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{code="200",pool="main",tenant="acme"} 1
+test_requests_total{code="200",pool="main",tenant="globex"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("reset companions keep the series of other instances", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry)
+
+		type resettable struct {
+			Requests      func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			ResetRequests func()                          `metric:"Requests"`
+		}
+		var acme, globex resettable
+		require.NoError(t, initializer.InitInstance(&acme, "test", prometheus.Labels{"tenant": "acme"}))
+		require.NoError(t, initializer.InitInstance(&globex, "test", prometheus.Labels{"tenant": "globex"}))
+
+		acme.Requests(labels{Code: 200}).Inc()
+		globex.Requests(labels{Code: 200}).Inc()
+		acme.ResetRequests()
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{code="200",tenant="globex"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("different namespaces", func(t *testing.T) {
+		initializer := newInitializer(prometheus.NewRegistry())
+
+		var first, second metrics
+		require.NoError(t, initializer.InitInstance(&first, "first", prometheus.Labels{"tenant": "acme"}))
+		assert.NoError(t, initializer.InitInstance(&second, "second", prometheus.Labels{"tenant": "acme"}))
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		t.Run("different instance label names", func(t *testing.T) {
+			initializer := newInitializer(prometheus.NewRegistry())
+
+			var first, second metrics
+			require.NoError(t, initializer.InitInstance(&first, "test", prometheus.Labels{"tenant": "acme"}))
+			assert.Error(t, initializer.InitInstance(&second, "test", prometheus.Labels{"pool": "main"}))
+		})
+
+		t.Run("instance label declared by a metric", func(t *testing.T) {
+			var metrics struct {
+				Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			}
+			err := newInitializer(prometheus.NewRegistry()).InitInstance(&metrics, "test", prometheus.Labels{"code": "200"})
+			assert.True(t, errors.Is(err, ErrDuplicateLabel{Label: "code"}))
+		})
+
+		t.Run("callbacks", func(t *testing.T) {
+			var metrics struct {
+				Pool struct {
+					Depth GaugeFunc `name:"depth" help:"Depth"`
+				} `namespace:"pool"`
+			}
+			err := newInitializer(prometheus.NewRegistry()).InitInstance(&metrics, "test", prometheus.Labels{})
+			assert.EqualError(t, err, "field Pool.Depth: callbacks can't be declared in metric instances, since the instances share the registered metrics")
+		})
+
+		t.Run("initialized by Init", func(t *testing.T) {
+			initializer := newInitializer(prometheus.NewRegistry())
+
+			var first, second metrics
+			require.NoError(t, initializer.Init(&first, "test"))
+			assert.Error(t, initializer.InitInstance(&second, "test", prometheus.Labels{"tenant": "acme"}))
+		})
+
+		t.Run("not a pointer", func(t *testing.T) {
+			err := newInitializer(prometheus.NewRegistry()).InitInstance(metrics{}, "test", prometheus.Labels{"tenant": "acme"})
+			assert.Error(t, err)
+		})
+	})
+}
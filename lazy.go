@@ -0,0 +1,63 @@
+package gotoprom
+
+import (
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// lazyMetric returns a metric built with the result provided whose factory registers it the first time it's called.
+// Registration errors are handled by the error handler, and the metrics built are still returned, although they aren't collected.
+func (in *initializer) lazyMetric(result BuildResult, ctx BuildContext, metricType reflect.Type, registerers []prometheus.Registerer) builtMetric {
+	var once sync.Once
+	var mu sync.Mutex
+	var factory func(prometheus.Labels) interface{}
+	var collector prometheus.Collector
+
+	register := func() {
+		registered, err := in.register(result, ctx, metricType, registerers)
+		if err != nil {
+			in.handleError(prometheus.BuildFQName(ctx.Namespace, "", ctx.Name), registerErrorKind, err)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		factory, collector = registered.Factory, registered.Collector
+	}
+
+	return builtMetric{
+		factory: func(labels prometheus.Labels) interface{} {
+			once.Do(register)
+			return factory(labels)
+		},
+		noOp: func(labels prometheus.Labels) interface{} {
+			if result.NoOp == nil {
+				return nil
+			}
+			return result.NoOp(labels)
+		},
+		collectors: func() []prometheus.Collector {
+			mu.Lock()
+			defer mu.Unlock()
+			if collector == nil {
+				return nil
+			}
+			return []prometheus.Collector{collector}
+		},
+	}
+}
+
+// handleError handles an error of the kind provided found after the metric provided was initialized
+// with the error handler of the Initializer, or logs it if it has none
+func (in *initializer) handleError(metric, kind string, err error) {
+	if in.self != nil {
+		in.self.runtimeErrors.WithLabelValues(metric, kind).Inc()
+	}
+
+	if in.options.ErrorHandler != nil {
+		in.options.ErrorHandler(err)
+		return
+	}
+	log.Printf("gotoprom: %s", err)
+}
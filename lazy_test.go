@@ -0,0 +1,131 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_Lazy(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(registry, opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+		return initializer
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			Eager func() prometheus.Counter `name:"eager_total" help:"Eager"`
+			Lazy  func() prometheus.Counter `name:"lazy_total" help:"Lazy" lazy:"true"`
+			Group struct {
+				Used    func() prometheus.Counter `name:"used_total" help:"Used"`
+				NotUsed func() prometheus.Counter `name:"not_used_total" help:"Not used"`
+				OptOut  func() prometheus.Counter `name:"opt_out_total" help:"Opt out" lazy:"false"`
+			} `namespace:"group" lazy:"true"`
+		}
+		err := newInitializer(registry).Init(&metrics, "test")
+		require.NoError(t, err)
+		assert.True(t, isRegistered(registry, "test_eager_total", "Eager"))
+		assert.True(t, isRegistered(registry, "test_group_opt_out_total", "Opt out"))
+		assert.False(t, isRegistered(registry, "test_lazy_total", "Lazy"))
+		assert.False(t, isRegistered(registry, "test_group_used_total", "Used"))
+
+		metrics.Eager().Inc()
+		metrics.Lazy().Inc()
+		metrics.Lazy().Inc()
+		metrics.Group.Used().Inc()
+
+		expected := `
+# HELP test_eager_total Eager
+# TYPE test_eager_total counter
+test_eager_total 1
+# HELP test_group_used_total Used
+# TYPE test_group_used_total counter
+test_group_used_total 1
+# HELP test_lazy_total Lazy
+# TYPE test_lazy_total counter
+test_lazy_total 2
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+		assert.False(t, isRegistered(registry, "test_group_not_used_total", "Not used"))
+	})
+
+	t.Run("lazy registration option", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+
+		var metrics struct {
+			Lazy  func() prometheus.Counter `name:"lazy_total" help:"Lazy"`
+			Eager func() prometheus.Counter `name:"eager_total" help:"Eager" lazy:"false"`
+		}
+		err := newInitializer(registry, WithLazyRegistration()).Init(&metrics, "test")
+		require.NoError(t, err)
+		assert.True(t, isRegistered(registry, "test_eager_total", "Eager"))
+		assert.False(t, isRegistered(registry, "test_lazy_total", "Lazy"))
+
+		metrics.Lazy().Inc()
+		assert.True(t, isRegistered(registry, "test_lazy_total", "Lazy"))
+	})
+
+	t.Run("registration errors are handled", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(prometheus.NewGauge(prometheus.GaugeOpts{Name: "test_lazy_total", Help: "Already registered"}))
+
+		var errs []error
+		var metrics struct {
+			Lazy func() prometheus.Counter `name:"lazy_total" help:"Lazy" lazy:"true"`
+		}
+		err := newInitializer(registry, WithErrorHandler(func(err error) { errs = append(errs, err) })).Init(&metrics, "test")
+		require.NoError(t, err)
+
+		metrics.Lazy().Inc()
+		metrics.Lazy().Inc()
+		assert.Len(t, errs, 1, "metric is registered once")
+	})
+
+	t.Run("build errors fail to init", func(t *testing.T) {
+		var metrics struct {
+			Lazy func() prometheus.Histogram `name:"lazy_seconds" help:"Lazy" buckets:"wrong" lazy:"true"`
+		}
+		registry := prometheus.NewRegistry()
+		err := newInitializer(registry).Init(&metrics, "test")
+		assert.Error(t, err)
+		assert.False(t, isRegistered(registry, "test_lazy_seconds", "Lazy"))
+	})
+
+	t.Run("unknown tag keys fail to init", func(t *testing.T) {
+		var metrics struct {
+			Lazy func() prometheus.Histogram `name:"lazy_seconds" help:"Lazy" buckets:"1" bukets:"1" lazy:"true"`
+		}
+		initializer := NewInitializer(prometheus.NewRegistry(), WithStrictTagKeys())
+		initializer.MustAddBuilderV2(prometheusvanilla.HistogramType, DeclareTagKeys(prometheusvanilla.BuildHistogram, prometheusvanilla.HistogramTagKeys...))
+		err := initializer.Init(&metrics, "test")
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong lazy tag", func(t *testing.T) {
+		var metrics struct {
+			Lazy func() prometheus.Counter `name:"lazy_total" help:"Lazy" lazy:"yes"`
+		}
+		err := newInitializer(prometheus.NewRegistry()).Init(&metrics, "test")
+		assert.Error(t, err)
+	})
+}
+
+// isRegistered tells whether a metric with the name and help provided is registered in the registry
+func isRegistered(registry *prometheus.Registry, name, help string) bool {
+	probe := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	if err := registry.Register(probe); err != nil {
+		return true
+	}
+	registry.Unregister(probe)
+	return false
+}
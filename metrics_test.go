@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/cabify/gotoprom"
+	"github.com/cabify/gotoprom/prometheusx"
 	"github.com/prometheus/client_golang/prometheus/testutil"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -44,6 +45,38 @@ func Test_InitHappyCase(t *testing.T) {
 	metrics.NoLabels().Add(288.88)
 }
 
+func Test_TimeMetrics(t *testing.T) {
+	type labels struct {
+		Endpoint string `label:"endpoint"`
+	}
+
+	var metrics struct {
+		DurationSeconds      func(labels) prometheusx.TimeHistogram `name:"duration_seconds" help:"Duration in seconds" buckets:""`
+		DurationMilliseconds func(labels) prometheusx.TimeSummary   `name:"duration_milliseconds" help:"Duration in milliseconds" objectives:"" unit:"milliseconds"`
+		InFlight             func(labels) prometheusx.InFlightGauge `name:"in_flight" help:"Requests in flight"`
+	}
+
+	gotoprom.MustInit(&metrics, "testtime")
+
+	theseLabels := labels{Endpoint: "/"}
+	metrics.DurationSeconds(theseLabels).Duration(time.Second)
+	metrics.DurationMilliseconds(theseLabels).Duration(time.Second)
+	metrics.InFlight(theseLabels).Track(func() {})
+
+	expected := `
+# HELP testtime_duration_milliseconds Duration in milliseconds
+# TYPE testtime_duration_milliseconds summary
+testtime_duration_milliseconds_sum{endpoint="/"} 1000
+testtime_duration_milliseconds_count{endpoint="/"} 1
+# HELP testtime_in_flight Requests in flight
+# TYPE testtime_in_flight gauge
+testtime_in_flight{endpoint="/"} 0
+`
+	err := testutil.GatherAndCompare(prometheus.DefaultGatherer, strings.NewReader(expected), "testtime_duration_milliseconds", "testtime_in_flight")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"endpoint": "/"}, retrieveReportedLabels(t, "testtime_duration_seconds"))
+}
+
 func Test_NestedMetrics(t *testing.T) {
 	type testLabels struct {
 		TestLabel string `label:"test_label"`
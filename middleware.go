@@ -0,0 +1,57 @@
+package gotoprom
+
+import (
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Middleware wraps a Builder, it's used to add behavior to all the builders of an Initializer,
+// like logging the registrations, normalizing the labels or decorating the metrics built.
+type Middleware func(Builder) Builder
+
+// MiddlewareV2 wraps a BuilderV2, like Middleware does with a Builder
+type MiddlewareV2 func(BuilderV2) BuilderV2
+
+// adaptMiddleware adapts a Middleware to a MiddlewareV2
+// The NoOp of the wrapped BuilderV2 is kept as is, since a Builder can't provide one.
+func adaptMiddleware(mw Middleware) MiddlewareV2 {
+	return func(next BuilderV2) BuilderV2 {
+		return func(ctx BuildContext) (BuildResult, error) {
+			var result BuildResult
+			builder := mw(func(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+				c := ctx
+				c.Name, c.Help, c.Namespace, c.LabelNames, c.Tag = name, help, namespace, labelNames, tag
+
+				var err error
+				if result, err = next(c); err != nil {
+					return nil, nil, err
+				}
+				return result.Factory, result.Collector, nil
+			})
+
+			factory, collector, err := builder(ctx.Name, ctx.Help, ctx.Namespace, ctx.LabelNames, ctx.Tag)
+			if err != nil {
+				return BuildResult{}, err
+			}
+			return BuildResult{
+				Factory:   factory,
+				NoOp:      result.NoOp,
+				Collector: collector,
+				Desc:      describe(collector),
+				TagKeys:   result.TagKeys,
+			}, nil
+		}
+	}
+}
+
+// wrap wraps the builder provided with the middlewares of the Initializer, the first one used being the outermost
+func (in *initializer) wrap(builder BuilderV2) BuilderV2 {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	for i := len(in.middlewares) - 1; i >= 0; i-- {
+		builder = in.middlewares[i](builder)
+	}
+	return builder
+}
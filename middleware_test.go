@@ -0,0 +1,109 @@
+package gotoprom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mirroredGauge struct {
+	prometheus.Gauge
+	mirror prometheus.Gauge
+}
+
+func (g mirroredGauge) Set(v float64) {
+	g.Gauge.Set(v)
+	g.mirror.Set(v)
+}
+
+func TestInitializer_Use(t *testing.T) {
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		mirror := prometheus.NewGauge(prometheus.GaugeOpts{Name: "mirror", Help: "Mirror"})
+		require.NoError(t, registry.Register(mirror))
+
+		initializer := NewInitializer(registry)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var calls []string
+		initializer.Use(func(next Builder) Builder {
+			return func(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+				calls = append(calls, "outer "+name)
+				return next(strings.ToLower(name), help, namespace, labelNames, tag)
+			}
+		})
+		initializer.UseV2(func(next BuilderV2) BuilderV2 {
+			return func(ctx BuildContext) (BuildResult, error) {
+				calls = append(calls, "inner "+ctx.Name+" "+ctx.FieldPath)
+				result, err := next(ctx)
+				if err != nil {
+					return result, err
+				}
+				factory := result.Factory
+				result.Factory = func(labels prometheus.Labels) interface{} {
+					return mirroredGauge{Gauge: factory(labels).(prometheus.Gauge), mirror: mirror}
+				}
+				return result, nil
+			}
+		})
+
+		var metrics struct {
+			Gauge func() prometheus.Gauge `name:"Gauge" help:"Some gauge"`
+		}
+		err := initializer.Init(&metrics, "test")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"outer Gauge", "inner gauge Gauge"}, calls)
+
+		metrics.Gauge().Set(42)
+
+		expected := `
+# HELP mirror Mirror
+# TYPE mirror gauge
+mirror 42
+# HELP test_gauge Some gauge
+# TYPE test_gauge gauge
+test_gauge 42
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+	})
+
+	t.Run("middlewares are not shared with clones", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		clone := initializer.Clone()
+
+		initializer.UseV2(func(BuilderV2) BuilderV2 {
+			return func(BuildContext) (BuildResult, error) {
+				return BuildResult{}, assert.AnError
+			}
+		})
+
+		var metrics struct {
+			Gauge func() prometheus.Gauge `name:"gauge" help:"Some gauge"`
+		}
+		assert.Error(t, initializer.Init(&metrics, "test"))
+		assert.NoError(t, clone.Init(&metrics, "test"))
+	})
+
+	t.Run("fails when the middleware fails", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry())
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		initializer.Use(func(Builder) Builder {
+			return func(string, string, string, []string, reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+				return nil, nil, assert.AnError
+			}
+		})
+
+		var metrics struct {
+			Gauge func() prometheus.Gauge `name:"gauge" help:"Some gauge"`
+		}
+		assert.Error(t, initializer.Init(&metrics, "test"))
+	})
+}
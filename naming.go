@@ -0,0 +1,126 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// units are the unit suffixes that should be declared through the unit tag when naming metrics strictly
+var units = []string{"seconds", "milliseconds", "bytes", "meters", "grams", "volts", "amperes", "joules", "celsius", "ratio"}
+
+// reservedSuffixes are the suffixes of the series generated for histograms and summaries
+var reservedSuffixes = []string{"_bucket", "_count", "_sum"}
+
+// ErrInvalidName is the error found when naming metrics strictly, if a metric or label name doesn't follow the Prometheus conventions
+type ErrInvalidName struct {
+	// Field is the path of the metric, like Requests.Total
+	Field string
+	// Name is the name of the metric or the label
+	Name string
+	// Reason explains why the name is invalid
+	Reason string
+}
+
+// Error implements error
+func (e ErrInvalidName) Error() string {
+	return fmt.Sprintf("field %s: invalid name %q: %s", e.Field, e.Name, e.Reason)
+}
+
+// Is tells whether the target is an ErrInvalidName for the same field and name, empty values in the target match any
+func (e ErrInvalidName) Is(target error) bool {
+	t, ok := target.(ErrInvalidName)
+	return ok && (t.Field == "" || t.Field == e.Field) && (t.Name == "" || t.Name == e.Name) && (t.Reason == "" || t.Reason == e.Reason)
+}
+
+// metricKind is the kind of a metric, as far as naming it is concerned
+type metricKind int
+
+const (
+	untypedKind metricKind = iota
+	counterKind
+	gaugeKind
+	// observerKind are histograms and summaries
+	observerKind
+)
+
+var observerType = reflect.TypeOf((*prometheus.Observer)(nil)).Elem()
+
+// kindOf guesses the kind of the metrics of the type provided from the methods they implement
+func kindOf(typ reflect.Type) metricKind {
+	switch {
+	case typ.Implements(prometheusvanilla.GaugeType):
+		return gaugeKind
+	case typ.Implements(prometheusvanilla.CounterType):
+		return counterKind
+	case typ.Implements(observerType):
+		return observerKind
+	}
+	return untypedKind
+}
+
+// checkNaming checks that the name and the labels of the metric of kind provided follow the Prometheus conventions,
+// the unit tag provided is the unit the name should end with. All the violations found are returned in an InitError.
+func checkNaming(path, fqName string, kind metricKind, labelNames []string, unit string) error {
+	var errs initErrors
+	invalid := func(name, reason string, args ...interface{}) {
+		errs.add(ErrInvalidName{Field: path, Name: name, Reason: fmt.Sprintf(reason, args...)})
+	}
+
+	if !model.IsValidMetricName(model.LabelValue(fqName)) {
+		invalid(fqName, "not a valid metric name")
+	}
+
+	base := fqName
+	if kind == counterKind {
+		if !strings.HasSuffix(fqName, "_total") {
+			invalid(fqName, "counters should end with _total")
+		}
+		base = strings.TrimSuffix(fqName, "_total")
+	}
+	if unit != "" {
+		if !strings.HasSuffix(base, "_"+unit) {
+			invalid(fqName, "metrics with unit %s should end with _%s", unit, unit)
+		}
+	} else {
+		for _, u := range units {
+			if strings.HasSuffix(base, "_"+u) {
+				invalid(fqName, "unit %s should be declared with the unit tag", u)
+			}
+		}
+	}
+	for _, suffix := range reservedSuffixes {
+		if strings.HasSuffix(fqName, suffix) {
+			invalid(fqName, "%s is a suffix of the series of histograms and summaries", suffix)
+		}
+	}
+
+	sorted := append([]string(nil), labelNames...)
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		switch {
+		case !model.LabelName(name).IsValid():
+			invalid(name, "not a valid label name")
+		case strings.HasPrefix(name, model.ReservedLabelPrefix):
+			invalid(name, "labels starting with %s are reserved", model.ReservedLabelPrefix)
+		case kind == observerKind && (name == model.BucketLabel || name == model.QuantileLabel):
+			invalid(name, "label is reserved for histograms and summaries")
+		}
+	}
+	return errs.err()
+}
+
+// withConstLabelNames returns the label names provided followed by the names of the const labels of the Initializer
+func (in *initializer) withConstLabelNames(labelNames []string) []string {
+	names := make([]string, len(labelNames), len(labelNames)+len(in.options.ConstLabels))
+	copy(names, labelNames)
+	for name := range in.options.ConstLabels {
+		names = append(names, name)
+	}
+	return names
+}
@@ -0,0 +1,156 @@
+package gotoprom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_StrictNaming(t *testing.T) {
+	newInitializer := func(opts ...Option) InitializerV2 {
+		initializer := NewInitializer(prometheus.NewRegistry(), opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+		initializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+		initializer.MustAddBuilder(prometheusx.TimeHistogramType, prometheusx.BuildTimeHistogram)
+		return initializer
+	}
+
+	type codeLabels struct {
+		Code string `label:"code"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		var metrics struct {
+			Requests func(codeLabels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			Duration func() prometheusx.TimeHistogram    `name:"duration_seconds" help:"Duration" buckets:"1" unit:"seconds"`
+			Size     func() prometheus.Gauge             `name:"size_bytes" help:"Size" unit:"bytes"`
+			Bytes    func() prometheus.Counter           `name:"sent_bytes_total" help:"Sent" unit:"bytes"`
+			Latency  func() prometheus.Summary           `name:"latency_seconds" help:"Latency" unit:"seconds" objectives:"0.5"`
+			Depth    GaugeFunc                           `name:"depth" help:"Depth"`
+			Errors   func() map[codeLabels]float64       `name:"errors_total" help:"Errors" type:"counter"`
+			Ratio    func(struct {
+				Le string `label:"le"`
+			}) prometheus.Gauge `name:"le_ratio" help:"le is not reserved in gauges" unit:"ratio"`
+		}
+		err := newInitializer(WithStrictNaming(), WithConstLabels(prometheus.Labels{"env": "test"})).Init(&metrics, "test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("names are not checked by default", func(t *testing.T) {
+		var metrics struct {
+			Requests func() prometheus.Counter `name:"requests" help:"Requests"`
+		}
+		err := newInitializer().Init(&metrics, "test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc     string
+			metrics  interface{}
+			expected ErrInvalidName
+		}{
+			{
+				desc: "counter without _total",
+				metrics: &struct {
+					Requests func() prometheus.Counter `name:"requests" help:"Requests"`
+				}{},
+				expected: ErrInvalidName{Field: "Requests", Name: "test_requests"},
+			},
+			{
+				desc: "counter callback without _total",
+				metrics: &struct {
+					Requests CounterFunc `name:"requests" help:"Requests"`
+				}{},
+				expected: ErrInvalidName{Field: "Requests", Name: "test_requests"},
+			},
+			{
+				desc: "unit without unit tag",
+				metrics: &struct {
+					Duration func() prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"1"`
+				}{},
+				expected: ErrInvalidName{Field: "Duration", Name: "test_duration_seconds"},
+			},
+			{
+				desc: "name not ending with the unit tag",
+				metrics: &struct {
+					Duration func() prometheusx.TimeHistogram `name:"duration" help:"Duration" buckets:"1" unit:"milliseconds"`
+				}{},
+				expected: ErrInvalidName{Field: "Duration", Name: "test_duration"},
+			},
+			{
+				desc: "invalid metric name",
+				metrics: &struct {
+					Requests func() prometheus.Gauge `name:"in-flight" help:"Requests"`
+				}{},
+				expected: ErrInvalidName{Field: "Requests", Name: "test_in-flight"},
+			},
+			{
+				desc: "histogram suffix",
+				metrics: &struct {
+					Requests func() prometheus.Gauge `name:"requests_count" help:"Requests"`
+				}{},
+				expected: ErrInvalidName{Field: "Requests", Name: "test_requests_count"},
+			},
+			{
+				desc: "le label in a histogram",
+				metrics: &struct {
+					Duration func(struct {
+						Le string `label:"le"`
+					}) prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"1" unit:"seconds"`
+				}{},
+				expected: ErrInvalidName{Field: "Duration", Name: "le"},
+			},
+			{
+				desc: "quantile label in a summary",
+				metrics: &struct {
+					Duration func(struct {
+						Q string `label:"quantile"`
+					}) prometheus.Summary `name:"duration_seconds" help:"Duration" unit:"seconds" objectives:"0.5"`
+				}{},
+				expected: ErrInvalidName{Field: "Duration", Name: "quantile"},
+			},
+			{
+				desc: "reserved label prefix",
+				metrics: &struct {
+					Depth func(struct {
+						Name string `label:"__name"`
+					}) prometheus.Gauge `name:"depth" help:"Depth"`
+				}{},
+				expected: ErrInvalidName{Field: "Depth", Name: "__name"},
+			},
+			{
+				desc: "invalid label name",
+				metrics: &struct {
+					Depth func(struct {
+						Name string `label:"queue-name"`
+					}) prometheus.Gauge `name:"depth" help:"Depth"`
+				}{},
+				expected: ErrInvalidName{Field: "Depth", Name: "queue-name"},
+			},
+			{
+				desc: "bundle field",
+				metrics: &struct {
+					Requests func() struct {
+						Count prometheus.Counter `name:"count" help:"Count"`
+						Size  prometheus.Gauge   `name:"size" help:"Size"`
+					} `name:"requests"`
+				}{},
+				expected: ErrInvalidName{Field: "Requests.Count", Name: "test_requests_count"},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				err := newInitializer(WithStrictNaming()).Init(tc.metrics, "test")
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, tc.expected), "expected %v, got %v", tc.expected, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,169 @@
+package gotoprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options are the configurable settings of an Initializer
+type Options struct {
+	// ConstLabels are added to all the metrics registered by the Initializer
+	ConstLabels prometheus.Labels
+	// AssignableBuilders enables using the builders whose types implement the types returned by the metrics
+	// when there's no builder for the exact type
+	AssignableBuilders bool
+	// StrictNaming enables checking that the metric and label names follow the Prometheus conventions
+	StrictNaming bool
+	// UnknownTagKey handles the unknown tag keys found in the fields, the error it returns fails the Init.
+	// Tag keys are not checked if it's nil.
+	UnknownTagKey func(ErrUnknownTagKey) error
+	// LazyRegistration makes all the metrics lazy, as if they had the lazy:"true" tag
+	LazyRegistration bool
+	// ErrorHandler handles the errors found after the metrics are initialized, like the ones registering lazy metrics.
+	// If it's nil the errors are logged.
+	ErrorHandler func(error)
+	// ReuseCollectors makes the Initializer reuse the collectors already registered for the same metrics
+	ReuseCollectors bool
+	// Registerers are the registerers of the registries the metrics can be registered in with the registry tag, by their names
+	Registerers map[string]prometheus.Registerer
+	// SelfMetrics makes the Initializer report metrics about the metrics it initializes
+	SelfMetrics bool
+	// MetricSeriesBudget is the maximum number of series of each metric, unless its budget tag says otherwise
+	MetricSeriesBudget int
+	// GlobalSeriesBudget is the maximum number of series of all the metrics initialized by the Initializer
+	GlobalSeriesBudget int
+	// BudgetPolicy is what the metric funcs do when they're called with a new label combination exceeding a series budget
+	BudgetPolicy BudgetPolicy
+	// BudgetHook is called when a metric func is called with a new label combination exceeding a series budget
+	BudgetHook func(BudgetExceeded)
+}
+
+// Option configures the Options of an Initializer
+type Option func(*Options)
+
+// WithConstLabels adds the labels provided to all the metrics registered by the Initializer
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(opts *Options) {
+		opts.ConstLabels = mergeLabels(opts.ConstLabels, labels)
+	}
+}
+
+// WithAssignableBuilders makes the Initializer use the builders whose types implement the type returned by a metric,
+// when there's no builder for that exact type. The builder tag can be used to select one of the builders when
+// several of them implement the type, like builder:"prometheus.Histogram"
+func WithAssignableBuilders() Option {
+	return func(opts *Options) {
+		opts.AssignableBuilders = true
+	}
+}
+
+// WithStrictNaming makes the Initializer check that the metric and label names follow the Prometheus conventions:
+// names and labels should be valid, counters should end with _total, units like _seconds should be declared
+// with the unit tag, names can't end with the suffixes of the histogram series, and labels can't be reserved ones,
+// like le in histograms, quantile in summaries or the ones starting with __
+func WithStrictNaming() Option {
+	return func(opts *Options) {
+		opts.StrictNaming = true
+	}
+}
+
+// WithStrictTagKeys makes the Initializer fail when a field has an unknown tag key, like a misspelled bucket:"1,2".
+// The tag keys accepted by a metric field are the ones accepted by all the metrics, like name or help, and the ones declared by its builder,
+// the tag keys of the metrics built by builders that don't declare them aren't checked. See DeclareTagKeys.
+func WithStrictTagKeys() Option {
+	return func(opts *Options) {
+		opts.UnknownTagKey = func(err ErrUnknownTagKey) error { return err }
+	}
+}
+
+// WithTagKeysWarning makes the Initializer call warn with the unknown tag keys found in the fields, like WithStrictTagKeys does,
+// but without failing.
+func WithTagKeysWarning(warn func(error)) Option {
+	return func(opts *Options) {
+		opts.UnknownTagKey = func(err ErrUnknownTagKey) error {
+			warn(err)
+			return nil
+		}
+	}
+}
+
+// WithLazyRegistration makes the Initializer register each metric the first time it's used,
+// as if all the metrics had the lazy:"true" tag. A metric or a group can still opt out with lazy:"false".
+func WithLazyRegistration() Option {
+	return func(opts *Options) {
+		opts.LazyRegistration = true
+	}
+}
+
+// WithErrorHandler sets the handler of the errors found after the metrics are initialized,
+// like the ones registering lazy metrics.
+func WithErrorHandler(handler func(error)) Option {
+	return func(opts *Options) {
+		opts.ErrorHandler = handler
+	}
+}
+
+// WithCollectorReuse makes the Initializer reuse the collector already registered for a metric, instead of failing,
+// so the same metrics can be initialized several times, like in tests or in several instances of a library.
+// The collector can only be reused if it was registered by gotoprom for a metric with the same name, help, labels and type.
+func WithCollectorReuse() Option {
+	return func(opts *Options) {
+		opts.ReuseCollectors = true
+	}
+}
+
+// WithRegisterer adds a registerer to the Initializer with the name provided, so metrics or groups can be registered in it
+// with the registry tag, like registry:"internal", or in several ones, like registry:"default,internal".
+// The prometheus.Registerer provided to NewInitializer is named DefaultRegistry, and it's used if there's no registry tag.
+func WithRegisterer(name string, registerer prometheus.Registerer) Option {
+	return func(opts *Options) {
+		registerers := make(map[string]prometheus.Registerer, len(opts.Registerers)+1)
+		for n, r := range opts.Registerers {
+			registerers[n] = r
+		}
+		registerers[name] = registerer
+		opts.Registerers = registerers
+	}
+}
+
+// WithSelfMetrics makes the Initializer register metrics about the metrics it initializes in its default registry:
+// the number of series of each metric in gotoprom_series, the errors found after initializing them,
+// like failures registering lazy metrics, in gotoprom_runtime_errors_total, and the duration of a sample of the calls
+// to their metric funcs in gotoprom_observe_duration_seconds.
+func WithSelfMetrics() Option {
+	return func(opts *Options) {
+		opts.SelfMetrics = true
+	}
+}
+
+// WithSeriesBudget limits the number of series of each metric to the budget provided,
+// the budget tag of a metric, like budget:"100", overrides it.
+// Calling a metric func with a new label combination once its budget is exhausted applies the BudgetPolicy,
+// which is DropSeries unless it's set with WithBudgetPolicy.
+func WithSeriesBudget(budget int) Option {
+	return func(opts *Options) {
+		opts.MetricSeriesBudget = budget
+	}
+}
+
+// WithGlobalSeriesBudget limits the number of series of all the metrics initialized by the Initializer to the budget provided,
+// applying the BudgetPolicy like WithSeriesBudget does
+func WithGlobalSeriesBudget(budget int) Option {
+	return func(opts *Options) {
+		opts.GlobalSeriesBudget = budget
+	}
+}
+
+// WithBudgetPolicy sets what the metric funcs do when they're called with a new label combination exceeding a series budget
+func WithBudgetPolicy(policy BudgetPolicy) Option {
+	return func(opts *Options) {
+		opts.BudgetPolicy = policy
+	}
+}
+
+// WithBudgetHook sets the hook called when a metric func is called with a new label combination exceeding a series budget,
+// which receives the labels struct provided to the metric func. Without a hook, the WarnSeries policy logs the label combination.
+func WithBudgetHook(hook func(BudgetExceeded)) Option {
+	return func(opts *Options) {
+		opts.BudgetHook = hook
+	}
+}
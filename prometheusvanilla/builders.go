@@ -22,6 +22,17 @@ var (
 	SummaryType = reflect.TypeOf((*prometheus.Summary)(nil)).Elem()
 )
 
+var (
+	// CounterTagKeys are the tag keys accepted by BuildCounter
+	CounterTagKeys = []string{}
+	// GaugeTagKeys are the tag keys accepted by BuildGauge
+	GaugeTagKeys = []string{}
+	// HistogramTagKeys are the tag keys accepted by BuildHistogram
+	HistogramTagKeys = []string{"buckets"}
+	// SummaryTagKeys are the tag keys accepted by BuildSummary
+	SummaryTagKeys = []string{"objectives", "max_age", "age_buckets", "buf_cap"}
+)
+
 // BuildCounter builds a prometheus.Counter in the given prometheus.Registerer
 // The function it returns returns a prometheus.Counter type as an interface{}
 func BuildCounter(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
@@ -84,9 +95,11 @@ func BuildHistogram(name, help, namespace string, labelNames []string, tag refle
 
 // BuildSummary builds a prometheus.Summary
 // The function it returns returns a prometheus.Summary type as an interface{}
-// It requires the objectives tag to be provided, and optionally the max_age tag
+// It requires the objectives tag to be provided, and optionally the max_age, age_buckets and buf_cap tags
 // If the objectives tag is explicitly empty, then the Summary will be built with default prometheus objectives
 // which is no objectives at the time this comment is written.
+// Each objective can optionally specify its absolute error after a colon, like objectives:"0.5:0.05,0.99:0.001",
+// otherwise the error is calculated from the quantile.
 func BuildSummary(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
 	maxAge, err := maxAgeFromTag(tag)
 	if err != nil {
@@ -96,6 +109,14 @@ func BuildSummary(name, help, namespace string, labelNames []string, tag reflect
 	if err != nil {
 		return nil, nil, fmt.Errorf("build summary %q: %s", name, err)
 	}
+	ageBuckets, err := uint32FromTag(tag, "age_buckets")
+	if err != nil {
+		return nil, nil, fmt.Errorf("build summary %q: %s", name, err)
+	}
+	bufCap, err := uint32FromTag(tag, "buf_cap")
+	if err != nil {
+		return nil, nil, fmt.Errorf("build summary %q: %s", name, err)
+	}
 
 	sum := prometheus.NewSummaryVec(
 		prometheus.SummaryOpts{
@@ -104,6 +125,8 @@ func BuildSummary(name, help, namespace string, labelNames []string, tag reflect
 			Namespace:  namespace,
 			MaxAge:     maxAge,
 			Objectives: objectives,
+			AgeBuckets: ageBuckets,
+			BufCap:     bufCap,
 		},
 		labelNames,
 	)
@@ -149,11 +172,30 @@ func maxAgeFromTag(tag reflect.StructTag) (time.Duration, error) {
 	return maxAgeDuration, nil
 }
 
+// uint32FromTag will return the positive integer value of the given tag key
+// if the tag is not present, it will return 0, which makes prometheus use its default value
+func uint32FromTag(tag reflect.StructTag, key string) (uint32, error) {
+	valueString, ok := tag.Lookup(key)
+	if !ok {
+		return 0, nil
+	}
+	value, err := strconv.ParseUint(valueString, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s tag specified: %s", key, err)
+	}
+	if value == 0 {
+		return 0, fmt.Errorf("invalid %s tag specified: should be greater than zero", key)
+	}
+	return uint32(value), nil
+}
+
 // objectivesFromTag will return the objectives from the tag provided
 // if there's no objectives tag, it will return an error
 // if objectives is an empty string, it will return a nil value instead of an initialized empty map
 // this is intended to initialize prometheus metric with default values, as prometheus will
 // check for the value to be nil instead of checking for its len to be 0 (like it does for buckets)
+// each objective is either a quantile, whose error will be calculated by absError,
+// or a quantile and its absolute error separated by a colon, like 0.99:0.001
 func objectivesFromTag(tag reflect.StructTag) (map[float64]float64, error) {
 	quantileString, ok := tag.Lookup("objectives")
 	if !ok {
@@ -167,11 +209,30 @@ func objectivesFromTag(tag reflect.StructTag) (map[float64]float64, error) {
 	quantileSlice := strings.Split(quantileString, ",")
 	objectives := make(map[float64]float64, len(quantileSlice))
 	for i := range quantileSlice {
-		q, err := strconv.ParseFloat(quantileSlice[i], 64)
+		parts := strings.SplitN(quantileSlice[i], ":", 2)
+		q, err := strconv.ParseFloat(parts[0], 64)
 		if err != nil {
 			return nil, fmt.Errorf("invalid objective specified: %s", err)
 		}
-		objectives[q] = absError(q)
+		if math.IsNaN(q) || q < 0 || q > 1 {
+			return nil, fmt.Errorf("invalid objective specified: quantile %v should be between 0 and 1", q)
+		}
+		if _, ok := objectives[q]; ok {
+			return nil, fmt.Errorf("invalid objective specified: quantile %v is specified twice", q)
+		}
+
+		if len(parts) == 1 {
+			objectives[q] = absError(q)
+			continue
+		}
+		e, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid error for objective %v specified: %s", q, err)
+		}
+		if math.IsNaN(e) || e < 0 || e > 1 {
+			return nil, fmt.Errorf("invalid error for objective %v specified: %v should be between 0 and 1", q, e)
+		}
+		objectives[q] = e
 	}
 	return objectives, nil
 }
@@ -29,6 +29,7 @@ var (
 	objectivesTag          reflect.StructTag = `name:"some_name" help:"some help for the metric" max_age:"1h" objectives:"0.55,0.95,0.98"`
 	emptyObjectivesTag     reflect.StructTag = `name:"some_name" help:"some help for the metric" max_age:"1h" objectives:""`
 	malformedObjectivesTag reflect.StructTag = `name:"some_name" help:"some help for the metric" max_age:"1h" objectives:"notFloat"`
+	explicitErrorsTag      reflect.StructTag = `name:"some_name" help:"some help for the metric" objectives:"0.5:0.05,0.99:0.001,0.999"`
 
 	expectedBuckets    = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10}
 	expectedMaxAge     = time.Hour
@@ -85,6 +86,26 @@ func TestBuilders(t *testing.T) {
 		_, _, err := BuildSummary(name, help, nameSpace, keys, `objectives:"."`)
 		assert.Error(t, err)
 	})
+
+	t.Run("Test building a summary with age buckets and buffer cap", func(t *testing.T) {
+		f, c, err := BuildSummary(name, help, nameSpace, keys, `objectives:"0.5:0.05" age_buckets:"3" buf_cap:"1000"`)
+		assert.NoError(t, err)
+		assert.Implements(t, (*prometheus.Collector)(nil), c)
+		assert.Implements(t, (*prometheus.Summary)(nil), f(labels))
+	})
+
+	for _, tag := range []reflect.StructTag{
+		`objectives:"" age_buckets:"three"`,
+		`objectives:"" age_buckets:"0"`,
+		`objectives:"" age_buckets:"-1"`,
+		`objectives:"" buf_cap:"4294967296"`,
+		`objectives:"" buf_cap:"0"`,
+	} {
+		t.Run(fmt.Sprintf("Test building a summary with malformed tag %s", tag), func(t *testing.T) {
+			_, _, err := BuildSummary(name, help, nameSpace, keys, tag)
+			assert.Error(t, err)
+		})
+	}
 }
 
 func TestBuckets(t *testing.T) {
@@ -140,6 +161,41 @@ func TestObjectives(t *testing.T) {
 		assert.Error(t, err)
 		assert.Nil(t, obj)
 	})
+	t.Run("Test parsing objectives with explicit errors", func(t *testing.T) {
+		obj, err := objectivesFromTag(explicitErrorsTag)
+		assert.NoError(t, err)
+		assert.Equal(t, map[float64]float64{0.5: 0.05, 0.99: 0.001, 0.999: 0}, obj)
+	})
+	for _, objectives := range []string{
+		"0.5:",
+		"0.5:foo",
+		"0.5:0.05:0.01",
+		"0.5:-0.1",
+		"0.5:1.5",
+		"1.5",
+		"-0.5:0.05",
+		"0.5,0.5:0.01",
+		"NaN",
+		"0.5:NaN",
+	} {
+		t.Run(fmt.Sprintf("Test returning error for malformed objectives %q", objectives), func(t *testing.T) {
+			_, err := objectivesFromTag(reflect.StructTag(fmt.Sprintf(`objectives:%q`, objectives)))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestUint32FromTag(t *testing.T) {
+	t.Run("Test it retrieves the value", func(t *testing.T) {
+		value, err := uint32FromTag(`buf_cap:"1000"`, "buf_cap")
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(1000), value)
+	})
+	t.Run("Test it returns 0 when the tag is not found", func(t *testing.T) {
+		value, err := uint32FromTag(defaultTag, "buf_cap")
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(0), value)
+	})
 }
 
 func TestAbsError(t *testing.T) {
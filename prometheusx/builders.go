@@ -0,0 +1,99 @@
+package prometheusx
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	// TimeHistogramType is the type of TimeHistogram interface
+	TimeHistogramType = reflect.TypeOf((*TimeHistogram)(nil)).Elem()
+	// TimeSummaryType is the type of TimeSummary interface
+	TimeSummaryType = reflect.TypeOf((*TimeSummary)(nil)).Elem()
+	// InFlightGaugeType is the type of InFlightGauge interface
+	InFlightGaugeType = reflect.TypeOf((*InFlightGauge)(nil)).Elem()
+)
+
+var (
+	// TimeHistogramTagKeys are the tag keys accepted by BuildTimeHistogram
+	TimeHistogramTagKeys = []string{"buckets", "unit"}
+	// TimeSummaryTagKeys are the tag keys accepted by BuildTimeSummary
+	TimeSummaryTagKeys = []string{"objectives", "max_age", "age_buckets", "buf_cap", "unit"}
+	// InFlightGaugeTagKeys are the tag keys accepted by BuildInFlightGauge
+	InFlightGaugeTagKeys = []string{}
+)
+
+// BuildTimeHistogram builds a TimeHistogram on top of a prometheus.Histogram
+// The function it returns returns a TimeHistogram type as an interface{}
+// It accepts the same tags as prometheusvanilla.BuildHistogram, and optionally the unit tag
+func BuildTimeHistogram(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+	unit, err := unitFromTag(tag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build time histogram %q: %s", name, err)
+	}
+
+	f, collector, err := prometheusvanilla.BuildHistogram(name, help, namespace, labelNames, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(labels prometheus.Labels) interface{} {
+		hist := f(labels).(prometheus.Histogram)
+		return timeHistogram{Histogram: hist, timeObserver: timeObserver{observer: hist, unit: unit}}
+	}, collector, nil
+}
+
+// BuildTimeSummary builds a TimeSummary on top of a prometheus.Summary
+// The function it returns returns a TimeSummary type as an interface{}
+// It accepts the same tags as prometheusvanilla.BuildSummary, and optionally the unit tag
+func BuildTimeSummary(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+	unit, err := unitFromTag(tag)
+	if err != nil {
+		return nil, nil, fmt.Errorf("build time summary %q: %s", name, err)
+	}
+
+	f, collector, err := prometheusvanilla.BuildSummary(name, help, namespace, labelNames, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(labels prometheus.Labels) interface{} {
+		sum := f(labels).(prometheus.Summary)
+		return timeSummary{Summary: sum, timeObserver: timeObserver{observer: sum, unit: unit}}
+	}, collector, nil
+}
+
+// BuildInFlightGauge builds an InFlightGauge on top of a prometheus.Gauge
+// The function it returns returns an InFlightGauge type as an interface{}
+func BuildInFlightGauge(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+	f, collector, err := prometheusvanilla.BuildGauge(name, help, namespace, labelNames, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return func(labels prometheus.Labels) interface{} {
+		return inFlightGauge{Gauge: f(labels).(prometheus.Gauge)}
+	}, collector, nil
+}
+
+// unitFromTag returns the duration of the unit specified in the unit tag
+// if there's no unit tag, seconds are used
+func unitFromTag(tag reflect.StructTag) (time.Duration, error) {
+	unit, ok := tag.Lookup("unit")
+	if !ok {
+		return time.Second, nil
+	}
+
+	switch unit {
+	case "seconds":
+		return time.Second, nil
+	case "milliseconds":
+		return time.Millisecond, nil
+	default:
+		return 0, fmt.Errorf("invalid unit %q specified: should be seconds or milliseconds", unit)
+	}
+}
@@ -0,0 +1,134 @@
+package prometheusx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	name      = "some_name"
+	help      = "Some help"
+	nameSpace = "some_namespace"
+)
+
+var (
+	labels = prometheus.Labels{"label": "value"}
+	keys   = []string{"label"}
+)
+
+func TestBuilders(t *testing.T) {
+	t.Run("Test building a time histogram", func(t *testing.T) {
+		f, c, err := BuildTimeHistogram(name, help, nameSpace, keys, `buckets:""`)
+		assert.NoError(t, err)
+		assert.Implements(t, (*prometheus.Collector)(nil), c)
+		assert.Implements(t, (*TimeHistogram)(nil), f(labels))
+	})
+
+	t.Run("Test building a time histogram with malformed buckets", func(t *testing.T) {
+		_, _, err := BuildTimeHistogram(name, help, nameSpace, keys, `buckets:"foo"`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Test building a time histogram with unknown unit", func(t *testing.T) {
+		_, _, err := BuildTimeHistogram(name, help, nameSpace, keys, `buckets:"" unit:"hours"`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Test building a time summary", func(t *testing.T) {
+		f, c, err := BuildTimeSummary(name, help, nameSpace, keys, `objectives:""`)
+		assert.NoError(t, err)
+		assert.Implements(t, (*prometheus.Collector)(nil), c)
+		assert.Implements(t, (*TimeSummary)(nil), f(labels))
+	})
+
+	t.Run("Test building a time summary without objectives", func(t *testing.T) {
+		_, _, err := BuildTimeSummary(name, help, nameSpace, keys, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("Test building a time summary with unknown unit", func(t *testing.T) {
+		_, _, err := BuildTimeSummary(name, help, nameSpace, keys, `objectives:"" unit:"hours"`)
+		assert.Error(t, err)
+	})
+
+	t.Run("Test building an in-flight gauge", func(t *testing.T) {
+		f, c, err := BuildInFlightGauge(name, help, nameSpace, keys, "")
+		assert.NoError(t, err)
+		assert.Implements(t, (*prometheus.Collector)(nil), c)
+		assert.Implements(t, (*InFlightGauge)(nil), f(labels))
+	})
+}
+
+func TestTimeObserver(t *testing.T) {
+	for _, tc := range []struct {
+		tag      reflect.StructTag
+		expected float64
+	}{
+		{tag: `buckets:""`, expected: 1.5},
+		{tag: `buckets:"" unit:"seconds"`, expected: 1.5},
+		{tag: `buckets:"" unit:"milliseconds"`, expected: 1500},
+	} {
+		t.Run(string(tc.tag), func(t *testing.T) {
+			f, _, err := BuildTimeHistogram(name, help, nameSpace, nil, tc.tag)
+			require.NoError(t, err)
+
+			f(nil).(TimeHistogram).Duration(1500 * time.Millisecond)
+			assert.Equal(t, tc.expected, histogramSum(t, f(nil)))
+		})
+	}
+
+	t.Run("Test since", func(t *testing.T) {
+		f, _, err := BuildTimeSummary(name, help, nameSpace, nil, `objectives:""`)
+		require.NoError(t, err)
+
+		f(nil).(TimeSummary).Since(time.Now().Add(-time.Hour))
+		assert.True(t, summarySum(t, f(nil)) >= time.Hour.Seconds())
+	})
+
+	t.Run("Test timer", func(t *testing.T) {
+		f, _, err := BuildTimeHistogram(name, help, nameSpace, nil, `buckets:""`)
+		require.NoError(t, err)
+
+		timer := f(nil).(TimeHistogram).Start()
+		time.Sleep(time.Millisecond)
+		elapsed := timer.Stop()
+
+		assert.True(t, elapsed >= time.Millisecond)
+		assert.Equal(t, elapsed.Seconds(), histogramSum(t, f(nil)))
+	})
+}
+
+func TestInFlightGauge(t *testing.T) {
+	f, _, err := BuildInFlightGauge(name, help, nameSpace, nil, "")
+	require.NoError(t, err)
+	gauge := f(nil).(InFlightGauge)
+
+	gauge.Track(func() {
+		assert.Equal(t, 1.0, gaugeValue(t, gauge))
+	})
+	assert.Equal(t, 0.0, gaugeValue(t, gauge))
+}
+
+func histogramSum(t *testing.T, metric interface{}) float64 {
+	return write(t, metric).GetHistogram().GetSampleSum()
+}
+
+func summarySum(t *testing.T, metric interface{}) float64 {
+	return write(t, metric).GetSummary().GetSampleSum()
+}
+
+func gaugeValue(t *testing.T, metric interface{}) float64 {
+	return write(t, metric).GetGauge().GetValue()
+}
+
+func write(t *testing.T, metric interface{}) *dto.Metric {
+	m := &dto.Metric{}
+	require.NoError(t, metric.(prometheus.Metric).Write(m))
+	return m
+}
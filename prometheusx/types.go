@@ -0,0 +1,95 @@
+package prometheusx
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TimeObserver offers time-observing functions on top of a prometheus.Observer
+// The durations are observed in the unit specified by the unit tag, which is seconds by default
+type TimeObserver interface {
+	// Duration observes the duration provided
+	Duration(duration time.Duration)
+	// Since observes the duration since the time point provided
+	Since(t0 time.Time)
+	// Start starts a Timer that will observe the duration elapsed when it's stopped:
+	//   defer metrics.Duration().Start().Stop()
+	Start() Timer
+}
+
+// TimeHistogram offers the basic prometheus.Histogram functionality
+// with additional time-observing functions
+type TimeHistogram interface {
+	prometheus.Histogram
+	TimeObserver
+}
+
+// TimeSummary offers the basic prometheus.Summary functionality
+// with additional time-observing functions
+type TimeSummary interface {
+	prometheus.Summary
+	TimeObserver
+}
+
+// InFlightGauge offers the basic prometheus.Gauge functionality
+// with an additional function to track the operations in progress
+type InFlightGauge interface {
+	prometheus.Gauge
+	// Track increments the gauge, calls f and decrements the gauge once f returns
+	Track(f func())
+}
+
+// Timer observes the duration elapsed since it was started
+type Timer struct {
+	observer TimeObserver
+	start    time.Time
+}
+
+// Stop observes the duration elapsed since the Timer was started and returns it
+func (t Timer) Stop() time.Duration {
+	elapsed := time.Since(t.start)
+	t.observer.Duration(elapsed)
+	return elapsed
+}
+
+type timeObserver struct {
+	observer prometheus.Observer
+	unit     time.Duration
+}
+
+// Duration observes the duration provided
+func (to timeObserver) Duration(duration time.Duration) {
+	to.observer.Observe(float64(duration) / float64(to.unit))
+}
+
+// Since observes the duration since the time point provided
+func (to timeObserver) Since(t0 time.Time) {
+	to.Duration(time.Since(t0))
+}
+
+// Start starts a Timer that will observe the duration elapsed when it's stopped
+func (to timeObserver) Start() Timer {
+	return Timer{observer: to, start: time.Now()}
+}
+
+type timeHistogram struct {
+	prometheus.Histogram
+	timeObserver
+}
+
+type timeSummary struct {
+	prometheus.Summary
+	timeObserver
+}
+
+type inFlightGauge struct {
+	prometheus.Gauge
+}
+
+// Track increments the gauge, calls f and decrements the gauge once f returns
+func (g inFlightGauge) Track(f func()) {
+	g.Inc()
+	defer g.Dec()
+	f()
+}
@@ -0,0 +1,215 @@
+package gotoprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Snapshot is the value of a metric at the moment it was read.
+// Its values are encoded in JSON as the "NaN", "+Inf" or "-Inf" strings when they're not finite,
+// like the quantiles of a summary without observations.
+type Snapshot struct {
+	// Type is the type of the metric: counter, gauge, histogram, summary or untyped
+	Type string
+	// Value is the value of counters, gauges and untyped metrics
+	Value float64
+	// SampleCount is the number of observations of histograms and summaries
+	SampleCount uint64
+	// SampleSum is the sum of the observations of histograms and summaries
+	SampleSum float64
+	// Buckets are the buckets of histograms, sorted by their upper bounds
+	Buckets []Bucket
+	// Quantiles are the quantiles of summaries, sorted by their ranks
+	Quantiles []Quantile
+}
+
+// snapshotJSON is the JSON encoding of a Snapshot
+type snapshotJSON struct {
+	Type        string     `json:"type"`
+	Value       jsonFloat  `json:"value"`
+	SampleCount uint64     `json:"sample_count,omitempty"`
+	SampleSum   jsonFloat  `json:"sample_sum,omitempty"`
+	Buckets     []Bucket   `json:"buckets,omitempty"`
+	Quantiles   []Quantile `json:"quantiles,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Snapshot) MarshalJSON() ([]byte, error) {
+	return json.Marshal(snapshotJSON{
+		Type:        s.Type,
+		Value:       jsonFloat(s.Value),
+		SampleCount: s.SampleCount,
+		SampleSum:   jsonFloat(s.SampleSum),
+		Buckets:     s.Buckets,
+		Quantiles:   s.Quantiles,
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Snapshot) UnmarshalJSON(data []byte) error {
+	var decoded snapshotJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*s = Snapshot{
+		Type:        decoded.Type,
+		Value:       float64(decoded.Value),
+		SampleCount: decoded.SampleCount,
+		SampleSum:   float64(decoded.SampleSum),
+		Buckets:     decoded.Buckets,
+		Quantiles:   decoded.Quantiles,
+	}
+	return nil
+}
+
+// Bucket is a bucket of a histogram Snapshot
+type Bucket struct {
+	// UpperBound is the inclusive upper bound of the bucket
+	UpperBound float64
+	// CumulativeCount is the number of observations lower than or equal to the upper bound
+	CumulativeCount uint64
+}
+
+// bucketJSON is the JSON encoding of a Bucket
+type bucketJSON struct {
+	UpperBound      jsonFloat `json:"upper_bound"`
+	CumulativeCount uint64    `json:"cumulative_count"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (b Bucket) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bucketJSON{UpperBound: jsonFloat(b.UpperBound), CumulativeCount: b.CumulativeCount})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Bucket) UnmarshalJSON(data []byte) error {
+	var decoded bucketJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*b = Bucket{UpperBound: float64(decoded.UpperBound), CumulativeCount: decoded.CumulativeCount}
+	return nil
+}
+
+// Quantile is a quantile of a summary Snapshot
+type Quantile struct {
+	// Quantile is the rank of the quantile, like 0.99
+	Quantile float64
+	// Value is the value of the quantile
+	Value float64
+}
+
+// quantileJSON is the JSON encoding of a Quantile
+type quantileJSON struct {
+	Quantile jsonFloat `json:"quantile"`
+	Value    jsonFloat `json:"value"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (q Quantile) MarshalJSON() ([]byte, error) {
+	return json.Marshal(quantileJSON{Quantile: jsonFloat(q.Quantile), Value: jsonFloat(q.Value)})
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (q *Quantile) UnmarshalJSON(data []byte) error {
+	var decoded quantileJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*q = Quantile{Quantile: float64(decoded.Quantile), Value: float64(decoded.Value)}
+	return nil
+}
+
+// jsonFloat is a float64 encoded in JSON as the "NaN", "+Inf" or "-Inf" strings when it's not finite,
+// since JSON numbers can't represent those values
+type jsonFloat float64
+
+// MarshalJSON implements json.Marshaler
+func (f jsonFloat) MarshalJSON() ([]byte, error) {
+	if v := float64(f); math.IsNaN(v) || math.IsInf(v, 0) {
+		return json.Marshal(strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	return json.Marshal(float64(f))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *jsonFloat) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return json.Unmarshal(data, (*float64)(f))
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("expected a number, NaN, +Inf or -Inf, got %q", s)
+	}
+	*f = jsonFloat(v)
+	return nil
+}
+
+// Read returns a Snapshot of the current value of the metric returned by the metric function provided for the labels provided,
+// like Read(metrics.Requests, requestLabels{Code: 200}), or Read(metrics.Uptime) for metrics without labels.
+//
+// The metric is read by calling the metric function, since it can't be told apart from other functions,
+// so reading it has the same effects as reporting to it:
+// its series is created if it didn't exist, and it uses up the series budget like any new label combination,
+// so the snapshot can be the one of the overflow series, or of a metric whose values aren't collected, if the budget is exceeded.
+// It also counts as a use of the series for the ttl tag, and as a call to the metric function in the self metrics.
+func Read(metricFunc interface{}, labels ...interface{}) (Snapshot, error) {
+	f := reflect.ValueOf(metricFunc)
+	if f.Kind() != reflect.Func || f.IsNil() {
+		return Snapshot{}, fmt.Errorf("expected a metric function, got %T", metricFunc)
+	}
+	if f.Type().NumIn() != len(labels) || f.Type().NumOut() != 1 {
+		return Snapshot{}, fmt.Errorf("expected %d labels for metric function %s, got %d", f.Type().NumIn(), f.Type(), len(labels))
+	}
+
+	args := make([]reflect.Value, len(labels))
+	for i, l := range labels {
+		args[i] = reflect.ValueOf(l)
+		if !args[i].IsValid() || !args[i].Type().AssignableTo(f.Type().In(i)) {
+			return Snapshot{}, fmt.Errorf("expected labels of type %s for metric function %s, got %T", f.Type().In(i), f.Type(), l)
+		}
+	}
+
+	out := f.Call(args)[0]
+	metric, ok := out.Interface().(prometheus.Metric)
+	if !ok {
+		return Snapshot{}, fmt.Errorf("metric %T returned by metric function %s can't be read", out.Interface(), f.Type())
+	}
+
+	var m dto.Metric
+	if err := metric.Write(&m); err != nil {
+		return Snapshot{}, fmt.Errorf("read metric %s: %s", metric.Desc(), err)
+	}
+	return snapshotFromMetric(&m), nil
+}
+
+// snapshotFromMetric builds the Snapshot of the metric provided
+func snapshotFromMetric(m *dto.Metric) Snapshot {
+	switch {
+	case m.Counter != nil:
+		return Snapshot{Type: "counter", Value: m.Counter.GetValue()}
+	case m.Gauge != nil:
+		return Snapshot{Type: "gauge", Value: m.Gauge.GetValue()}
+	case m.Histogram != nil:
+		snapshot := Snapshot{Type: "histogram", SampleCount: m.Histogram.GetSampleCount(), SampleSum: m.Histogram.GetSampleSum()}
+		for _, b := range m.Histogram.GetBucket() {
+			snapshot.Buckets = append(snapshot.Buckets, Bucket{UpperBound: b.GetUpperBound(), CumulativeCount: b.GetCumulativeCount()})
+		}
+		return snapshot
+	case m.Summary != nil:
+		snapshot := Snapshot{Type: "summary", SampleCount: m.Summary.GetSampleCount(), SampleSum: m.Summary.GetSampleSum()}
+		for _, q := range m.Summary.GetQuantile() {
+			snapshot.Quantiles = append(snapshot.Quantiles, Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+		}
+		return snapshot
+	default:
+		return Snapshot{Type: "untyped", Value: m.GetUntyped().GetValue()}
+	}
+}
@@ -0,0 +1,124 @@
+package gotoprom
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRead(t *testing.T) {
+	initializer := NewInitializer(prometheus.NewRegistry())
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+	initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+	initializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+	initializer.MustAddBuilder(prometheusx.TimeHistogramType, prometheusx.BuildTimeHistogram)
+
+	type labels struct {
+		Code int `label:"code"`
+	}
+	type bundle struct {
+		Idle prometheus.Gauge `name:"idle" help:"Idle"`
+	}
+	var metrics struct {
+		Requests func(labels) prometheus.Counter  `name:"requests_total" help:"Requests"`
+		Workers  func() prometheus.Gauge          `name:"workers" help:"Workers"`
+		Duration func() prometheusx.TimeHistogram `name:"duration_seconds" help:"Duration" buckets:"1,5"`
+		Size     func(labels) prometheus.Summary  `name:"size_bytes" help:"Size" objectives:"0.5,0.9"`
+		Bundle   func() bundle                    `name:"bundle"`
+	}
+	require.NoError(t, initializer.Init(&metrics, "test"))
+
+	t.Run("counter", func(t *testing.T) {
+		metrics.Requests(labels{Code: 200}).Add(3)
+
+		snapshot, err := Read(metrics.Requests, labels{Code: 200})
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{Type: "counter", Value: 3}, snapshot)
+	})
+
+	t.Run("gauge", func(t *testing.T) {
+		metrics.Workers().Set(4)
+
+		snapshot, err := Read(metrics.Workers)
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{Type: "gauge", Value: 4}, snapshot)
+	})
+
+	t.Run("histogram", func(t *testing.T) {
+		metrics.Duration().Duration(2 * time.Second)
+
+		snapshot, err := Read(metrics.Duration)
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{
+			Type:        "histogram",
+			SampleCount: 1,
+			SampleSum:   2,
+			Buckets:     []Bucket{{UpperBound: 1, CumulativeCount: 0}, {UpperBound: 5, CumulativeCount: 1}},
+		}, snapshot)
+	})
+
+	t.Run("summary", func(t *testing.T) {
+		metrics.Size(labels{Code: 200}).Observe(10)
+
+		snapshot, err := Read(metrics.Size, labels{Code: 200})
+		require.NoError(t, err)
+		assert.Equal(t, "summary", snapshot.Type)
+		assert.Equal(t, uint64(1), snapshot.SampleCount)
+		require.Len(t, snapshot.Quantiles, 2)
+		assert.Equal(t, Quantile{Quantile: 0.5, Value: 10}, snapshot.Quantiles[0])
+		assert.Equal(t, 0.9, snapshot.Quantiles[1].Quantile)
+	})
+
+	t.Run("series not reported yet", func(t *testing.T) {
+		snapshot, err := Read(metrics.Requests, labels{Code: 500})
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{Type: "counter", Value: 0}, snapshot)
+	})
+
+	t.Run("series budget", func(t *testing.T) {
+		initializer := NewInitializer(prometheus.NewRegistry(), WithSeriesBudget(1), WithBudgetPolicy(OverflowSeries))
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		var budgeted struct {
+			Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+		}
+		require.NoError(t, initializer.Init(&budgeted, "test"))
+
+		_, err := Read(budgeted.Requests, labels{Code: 200})
+		require.NoError(t, err)
+		budgeted.Requests(labels{Code: 500}).Inc()
+
+		snapshot, err := Read(budgeted.Requests, labels{Code: 200})
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{Type: "counter", Value: 0}, snapshot, "the series read used up the budget")
+		snapshot, err = Read(budgeted.Requests, labels{Code: 500})
+		require.NoError(t, err)
+		assert.Equal(t, Snapshot{Type: "counter", Value: 1}, snapshot, "the overflow series is read")
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc   string
+			metric interface{}
+			labels []interface{}
+		}{
+			{desc: "not a func", metric: math.Pi},
+			{desc: "nil func", metric: (func() prometheus.Gauge)(nil)},
+			{desc: "missing labels", metric: metrics.Requests},
+			{desc: "unexpected labels", metric: metrics.Workers, labels: []interface{}{labels{}}},
+			{desc: "wrong labels type", metric: metrics.Requests, labels: []interface{}{struct{}{}}},
+			{desc: "nil labels", metric: metrics.Requests, labels: []interface{}{nil}},
+			{desc: "bundle", metric: metrics.Bundle},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				_, err := Read(tc.metric, tc.labels...)
+				assert.Error(t, err)
+			})
+		}
+	})
+}
@@ -0,0 +1,61 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultRegistry is the name of the prometheus.Registerer provided to NewInitializer,
+// where the metrics without the registry tag are registered
+const DefaultRegistry = "default"
+
+// withRegistryTag returns a copy of the scope whose metrics are registered in the registries of the registry tag provided,
+// if it's defined, like registry:"internal" or registry:"default,internal"
+func (s scope) withRegistryTag(tag reflect.StructTag) (scope, error) {
+	registry, ok := tag.Lookup("registry")
+	if !ok {
+		return s, nil
+	}
+
+	names := strings.Split(registry, ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+		if names[i] == "" {
+			return s, fmt.Errorf("field %s: registry tag has an empty registry name: %q", s.fieldPath(), registry)
+		}
+	}
+	s.registries = names
+	return s, nil
+}
+
+// registerersFor returns the registerers of the registries of the scope provided
+func (in *initializer) registerersFor(s scope) ([]prometheus.Registerer, error) {
+	if len(s.registries) == 0 {
+		return []prometheus.Registerer{in.registerers[DefaultRegistry]}, nil
+	}
+
+	registerers := make([]prometheus.Registerer, len(s.registries))
+	for i, name := range s.registries {
+		registerer, ok := in.registerers[name]
+		if !ok {
+			return nil, fmt.Errorf("field %s: unknown registry %q, it should be added with WithRegisterer", s.fieldPath(), name)
+		}
+		registerers[i] = registerer
+	}
+	return registerers, nil
+}
+
+// isAlreadyRegistered tells whether the error is a prometheus.AlreadyRegisteredError for the collector provided,
+// which happens when the same collector is registered twice in a registry through different registerers
+func isAlreadyRegistered(err error, collector prometheus.Collector) bool {
+	alreadyRegistered, ok := err.(prometheus.AlreadyRegisteredError)
+	return ok && sameCollector(alreadyRegistered.ExistingCollector, collector)
+}
+
+// sameCollector tells whether both collectors are the same one, collectors that can't be compared are never the same
+func sameCollector(a, b prometheus.Collector) bool {
+	return reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Comparable() && a == b
+}
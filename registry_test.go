@@ -0,0 +1,163 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_Registries(t *testing.T) {
+	newInitializer := func(public, internal *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(public, append([]Option{WithRegisterer("internal", internal)}, opts...)...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		return initializer
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		public, internal := prometheus.NewRegistry(), prometheus.NewRegistry()
+		initializer := newInitializer(public, internal, WithConstLabels(prometheus.Labels{"env": "test"}))
+
+		var metrics struct {
+			Public   func() prometheus.Counter `name:"public_total" help:"Public"`
+			Internal func() prometheus.Counter `name:"internal_total" help:"Internal" registry:"internal"`
+			Both     func() prometheus.Counter `name:"both_total" help:"Both" registry:"default,internal"`
+			Debug    struct {
+				Cache struct {
+					Hits func() prometheus.Counter `name:"hits_total" help:"Hits"`
+				} `namespace:"cache"`
+				Gauge GaugeFunc `name:"gauge" help:"Gauge"`
+			} `namespace:"debug" registry:"internal"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+
+		metrics.Public().Inc()
+		metrics.Internal().Inc()
+		metrics.Both().Inc()
+		metrics.Debug.Cache.Hits().Inc()
+		metrics.Debug.Gauge = func() float64 { return 42 }
+
+		expectedPublic := `
+# HELP test_both_total Both
+# TYPE test_both_total counter
+test_both_total{env="test"} 1
+# HELP test_public_total Public
+# TYPE test_public_total counter
+test_public_total{env="test"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(public, strings.NewReader(expectedPublic)))
+
+		expectedInternal := `
+# HELP test_both_total Both
+# TYPE test_both_total counter
+test_both_total{env="test"} 1
+# HELP test_debug_cache_hits_total Hits
+# TYPE test_debug_cache_hits_total counter
+test_debug_cache_hits_total{env="test"} 1
+# HELP test_debug_gauge Gauge
+# TYPE test_debug_gauge gauge
+test_debug_gauge{env="test"} 42
+# HELP test_internal_total Internal
+# TYPE test_internal_total counter
+test_internal_total{env="test"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(internal, strings.NewReader(expectedInternal)))
+	})
+
+	t.Run("same registry with several names", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry, registry, WithConstLabels(prometheus.Labels{"env": "test"}))
+
+		var metrics struct {
+			Both func() prometheus.Counter `name:"both_total" help:"Both" registry:"default,internal"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+		metrics.Both().Inc()
+		assert.Equal(t, 1, testutil.CollectAndCount(metrics.Both()))
+	})
+
+	t.Run("lazy metrics", func(t *testing.T) {
+		public, internal := prometheus.NewRegistry(), prometheus.NewRegistry()
+		initializer := newInitializer(public, internal, WithLazyRegistration())
+
+		var metrics struct {
+			Internal func() prometheus.Counter `name:"internal_total" help:"Internal" registry:"internal"`
+		}
+		require.NoError(t, initializer.Init(&metrics, "test"))
+		assert.False(t, isRegistered(internal, "test_internal_total", "Internal"))
+
+		metrics.Internal().Inc()
+		assert.True(t, isRegistered(internal, "test_internal_total", "Internal"))
+		assert.False(t, isRegistered(public, "test_internal_total", "Internal"))
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "unknown registry",
+				metrics: &struct {
+					Requests func() prometheus.Counter `name:"requests_total" help:"Requests" registry:"debug"`
+				}{},
+			},
+			{
+				desc: "unknown registry of a group",
+				metrics: &struct {
+					Group struct {
+						Requests func() prometheus.Counter `name:"requests_total" help:"Requests"`
+					} `namespace:"group" registry:"default,debug"`
+				}{},
+			},
+			{
+				desc: "empty registry name",
+				metrics: &struct {
+					Requests func() prometheus.Counter `name:"requests_total" help:"Requests" registry:"default,"`
+				}{},
+			},
+			{
+				desc: "unknown registry of a callback",
+				metrics: &struct {
+					Gauge GaugeFunc `name:"gauge" help:"Gauge" registry:"debug"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				err := newInitializer(prometheus.NewRegistry(), prometheus.NewRegistry()).Init(tc.metrics, "test")
+				assert.Error(t, err)
+			})
+		}
+
+		t.Run("already registered in one of the registries", func(t *testing.T) {
+			public, internal := prometheus.NewRegistry(), prometheus.NewRegistry()
+			internal.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_both_total", Help: "Both"}))
+
+			var metrics struct {
+				Both func() prometheus.Counter `name:"both_total" help:"Both" registry:"default,internal"`
+			}
+			err := newInitializer(public, internal).Init(&metrics, "test")
+			assert.Error(t, err)
+		})
+	})
+}
+
+func TestInitializer_Validate_Registries(t *testing.T) {
+	internal := prometheus.NewRegistry()
+	initializer := NewInitializer(prometheus.NewRegistry(), WithRegisterer("internal", internal))
+	initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+	var metrics struct {
+		Internal func() prometheus.Counter `name:"internal_total" help:"Internal" registry:"internal"`
+		Unknown  func() prometheus.Counter `name:"unknown_total" help:"Unknown" registry:"debug"`
+	}
+	err := initializer.Validate(&metrics, "test")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "Unknown")
+	assert.NotContains(t, err.Error(), "Internal")
+	assert.False(t, isRegistered(internal, "test_internal_total", "Internal"))
+}
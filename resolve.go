@@ -0,0 +1,89 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// resolveBuilder finds the builder for the metric type provided, it returns nil if there's none.
+//
+// A builder is used when:
+//   - its type is exactly the metric type, or otherwise, if the Initializer resolves assignable builders:
+//   - its type is the one selected by the builder tag, like builder:"prometheus.Histogram", or otherwise
+//   - its type is the only one implementing the metric type, ignoring the types that are more specific than
+//     another implementing one (like prometheus.Gauge, which implements all the methods of prometheus.Counter)
+//
+// This allows declaring metrics returning narrower interfaces than the ones the builders produce, like prometheus.Observer.
+func (in *initializer) resolveBuilder(typ reflect.Type, tag reflect.StructTag) (BuilderV2, error) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	if builder, ok := in.builders[typ]; ok {
+		return builder, nil
+	}
+	if !in.options.AssignableBuilders {
+		return nil, nil
+	}
+
+	selected, hasSelected := tag.Lookup("builder")
+	if typ.Kind() != reflect.Interface {
+		if hasSelected {
+			return nil, fmt.Errorf("builder tag %q can't be used for %q, which is not an interface", selected, typ)
+		}
+		return nil, nil
+	}
+
+	if hasSelected {
+		for builderType, builder := range in.builders {
+			if builderType.String() != selected {
+				continue
+			}
+			if !builderType.Implements(typ) {
+				return nil, fmt.Errorf("builder %q doesn't implement %q", selected, typ)
+			}
+			return builder, nil
+		}
+		return nil, fmt.Errorf("no builder found for builder tag %q", selected)
+	}
+
+	var candidates []reflect.Type
+	for builderType := range in.builders {
+		if builderType.Implements(typ) {
+			candidates = append(candidates, builderType)
+		}
+	}
+
+	var resolved []reflect.Type
+	for _, candidate := range candidates {
+		if !moreSpecificThanAny(candidate, candidates) {
+			resolved = append(resolved, candidate)
+		}
+	}
+
+	switch len(resolved) {
+	case 0:
+		return nil, nil
+	case 1:
+		return in.builders[resolved[0]], nil
+	default:
+		names := make([]string, len(resolved))
+		for i, t := range resolved {
+			names[i] = t.String()
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("type %q is implemented by the types of several builders: %s, one of them should be selected using the builder tag", typ, strings.Join(names, ", "))
+	}
+}
+
+// moreSpecificThanAny tells whether typ implements any of the other interface types provided that don't implement typ,
+// concrete types are always more specific than the interfaces they implement
+func moreSpecificThanAny(typ reflect.Type, others []reflect.Type) bool {
+	for _, other := range others {
+		if other != typ && other.Kind() == reflect.Interface && typ.Implements(other) && !(typ.Kind() == reflect.Interface && other.Implements(typ)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,167 @@
+package gotoprom
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type setter interface {
+	Set(float64)
+}
+
+// wrappedCounter is a concrete metric type, implementing prometheus.Counter
+type wrappedCounter struct {
+	prometheus.Counter
+}
+
+var wrappedCounterType = reflect.TypeOf(wrappedCounter{})
+
+func buildWrappedCounter(name, help, namespace string, labelNames []string, tag reflect.StructTag) (func(prometheus.Labels) interface{}, prometheus.Collector, error) {
+	factory, collector, err := prometheusvanilla.BuildCounter(name, help, namespace, labelNames, tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	return func(labels prometheus.Labels) interface{} {
+		return wrappedCounter{factory(labels).(prometheus.Counter)}
+	}, collector, nil
+}
+
+func TestInitializer_Init_AssignableBuilders(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(registry, opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+		initializer.MustAddBuilder(prometheusvanilla.SummaryType, prometheusvanilla.BuildSummary)
+		initializer.MustAddBuilder(prometheusx.TimeHistogramType, prometheusx.BuildTimeHistogram)
+		initializer.MustAddBuilder(prometheusx.InFlightGaugeType, prometheusx.BuildInFlightGauge)
+		initializer.MustAddBuilder(wrappedCounterType, buildWrappedCounter)
+		return initializer
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry, WithAssignableBuilders())
+
+		var metrics struct {
+			Observer   func() prometheus.Observer  `name:"observer" help:"Observer built as a histogram" builder:"prometheus.Histogram" buckets:"1"`
+			Setter     func() setter               `name:"setter" help:"Setter built as a gauge"`
+			ExactMatch func() prometheus.Histogram `name:"exact_match" help:"Builder tag is ignored" builder:"prometheusx.TimeHistogram" buckets:"1"`
+		}
+		err := initializer.Init(&metrics, "test")
+		require.NoError(t, err)
+
+		metrics.Observer().Observe(0.5)
+		metrics.Setter().Set(42)
+		_, isTimeHistogram := metrics.ExactMatch().(prometheusx.TimeHistogram)
+		assert.False(t, isTimeHistogram)
+
+		expected := `
+# HELP test_observer Observer built as a histogram
+# TYPE test_observer histogram
+test_observer_bucket{le="1"} 1
+test_observer_bucket{le="+Inf"} 1
+test_observer_sum 0.5
+test_observer_count 1
+# HELP test_setter Setter built as a gauge
+# TYPE test_setter gauge
+test_setter 42
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_observer", "test_setter")
+		assert.NoError(t, err)
+	})
+
+	t.Run("concrete builders are more specific than the interfaces they implement", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry, WithAssignableBuilders())
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(wrappedCounterType, buildWrappedCounter)
+
+		var metrics struct {
+			Metric  func() prometheus.Metric `name:"metric" help:"Metric built as a counter"`
+			Wrapped func() wrappedCounter    `name:"wrapped" help:"Wrapped counter"`
+		}
+		err := initializer.Init(&metrics, "test")
+		require.NoError(t, err)
+
+		_, isCounter := metrics.Metric().(prometheus.Counter)
+		assert.True(t, isCounter)
+		_, isWrapped := metrics.Metric().(wrappedCounter)
+		assert.False(t, isWrapped)
+		metrics.Wrapped().Inc()
+
+		expected := `
+# HELP test_wrapped Wrapped counter
+# TYPE test_wrapped counter
+test_wrapped 1
+`
+		err = testutil.GatherAndCompare(registry, strings.NewReader(expected), "test_wrapped")
+		assert.NoError(t, err)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc    string
+			opts    []Option
+			metrics interface{}
+		}{
+			{
+				desc: "not resolving assignable builders",
+				metrics: &struct {
+					Foo func() prometheus.Observer `name:"observer" help:"Observer"`
+				}{},
+			},
+			{
+				desc: "ambiguous",
+				opts: []Option{WithAssignableBuilders()},
+				metrics: &struct {
+					Foo func() prometheus.Observer `name:"observer" help:"Could be a histogram or a summary"`
+				}{},
+			},
+			{
+				desc: "ambiguous in a bundle",
+				opts: []Option{WithAssignableBuilders()},
+				metrics: &struct {
+					Foo func() struct {
+						Metric prometheus.Metric `name:"metric" help:"Could be anything"`
+					} `name:"bundle"`
+				}{},
+			},
+			{
+				desc: "unknown builder tag",
+				opts: []Option{WithAssignableBuilders()},
+				metrics: &struct {
+					Foo func() prometheus.Observer `name:"observer" help:"Unknown builder" builder:"prometheus.Untyped"`
+				}{},
+			},
+			{
+				desc: "builder tag not implementing the type",
+				opts: []Option{WithAssignableBuilders()},
+				metrics: &struct {
+					Foo func() prometheus.Observer `name:"observer" help:"Counters can't observe" builder:"prometheus.Counter"`
+				}{},
+			},
+			{
+				desc: "builder tag for a concrete type",
+				opts: []Option{WithAssignableBuilders()},
+				metrics: &struct {
+					Foo func() struct{ prometheus.Counter } `name:"counter" help:"Not an interface" builder:"prometheus.Counter"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				initializer := newInitializer(prometheus.NewRegistry(), tc.opts...)
+				err := initializer.Init(tc.metrics, "namespace")
+				assert.Error(t, err)
+			})
+		}
+	})
+}
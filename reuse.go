@@ -0,0 +1,38 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reusableCollector is a collector registered by an Initializer with the ReuseCollectors option,
+// it holds the metric built along with the collector, so it can be reused while it's registered
+type reusableCollector struct {
+	prometheus.Collector
+	factory    func(prometheus.Labels) interface{}
+	metricType reflect.Type
+}
+
+// reuse returns the result provided using the collector that was already registered instead, along with the registered collector,
+// if the error provided is a prometheus.AlreadyRegisteredError for a collector registered by gotoprom
+// with the ReuseCollectors option for the same type.
+func (in *initializer) reuse(result BuildResult, ctx BuildContext, metricType reflect.Type, err error) (BuildResult, prometheus.Collector, error) {
+	alreadyRegistered, ok := err.(prometheus.AlreadyRegisteredError)
+	if !ok {
+		return result, nil, fmt.Errorf("field %s: register metric %q: can't reuse the registered metric, its labels or help are different: %s", ctx.FieldPath, ctx.Name, err)
+	}
+
+	existing, ok := alreadyRegistered.ExistingCollector.(*reusableCollector)
+	if !ok {
+		return result, nil, fmt.Errorf("field %s: register metric %q: can't reuse the registered metric, it wasn't registered by gotoprom with collector reuse", ctx.FieldPath, ctx.Name)
+	}
+	if existing.metricType != metricType && !(metricType.Kind() == reflect.Interface && existing.metricType.Implements(metricType)) {
+		return result, nil, fmt.Errorf("field %s: register metric %q: can't reuse the registered metric, its type %s is not %s", ctx.FieldPath, ctx.Name, existing.metricType, metricType)
+	}
+
+	result.Factory = existing.factory
+	result.Collector = existing.Collector
+	return result, existing, nil
+}
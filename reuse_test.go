@@ -0,0 +1,114 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/cabify/gotoprom/prometheusx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_CollectorReuse(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry, opts ...Option) InitializerV2 {
+		initializer := NewInitializer(registry, opts...)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+		initializer.MustAddBuilder(prometheusx.TimeHistogramType, prometheusx.BuildTimeHistogram)
+		return initializer
+	}
+
+	type labels struct {
+		Code int `label:"code"`
+	}
+	type metrics struct {
+		Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry, WithCollectorReuse(), WithConstLabels(prometheus.Labels{"env": "test"}))
+
+		var first, second metrics
+		require.NoError(t, initializer.Init(&first, "test"))
+		require.NoError(t, initializer.Init(&second, "test"))
+
+		first.Requests(labels{Code: 200}).Inc()
+		second.Requests(labels{Code: 200}).Inc()
+
+		expected := `
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{code="200",env="test"} 2
+`
+		err := testutil.GatherAndCompare(registry, strings.NewReader(expected))
+		assert.NoError(t, err)
+	})
+
+	t.Run("reuses collectors of metrics implementing the type", func(t *testing.T) {
+		initializer := newInitializer(prometheus.NewRegistry(), WithCollectorReuse())
+
+		var first struct {
+			Duration func() prometheusx.TimeHistogram `name:"duration_seconds" help:"Duration" buckets:"1"`
+		}
+		var second struct {
+			Duration func() prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"1"`
+		}
+		require.NoError(t, initializer.Init(&first, "test"))
+		assert.NoError(t, initializer.Init(&second, "test"))
+	})
+
+	t.Run("fails without the option", func(t *testing.T) {
+		initializer := newInitializer(prometheus.NewRegistry())
+
+		var first, second metrics
+		require.NoError(t, initializer.Init(&first, "test"))
+		assert.Error(t, initializer.Init(&second, "test"))
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "different labels",
+				metrics: &struct {
+					Requests func() prometheus.Counter `name:"requests_total" help:"Requests"`
+				}{},
+			},
+			{
+				desc: "different type",
+				metrics: &struct {
+					Requests func(labels) prometheus.Gauge `name:"requests_total" help:"Requests"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				initializer := newInitializer(prometheus.NewRegistry(), WithCollectorReuse())
+				require.NoError(t, initializer.Init(&metrics{}, "test"))
+				assert.Error(t, initializer.Init(tc.metrics, "test"))
+			})
+		}
+
+		t.Run("registered without the option", func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			require.NoError(t, newInitializer(registry).Init(&metrics{}, "test"))
+
+			err := newInitializer(registry, WithCollectorReuse()).Init(&metrics{}, "test")
+			assert.Error(t, err)
+		})
+
+		t.Run("not registered by gotoprom", func(t *testing.T) {
+			registry := prometheus.NewRegistry()
+			registry.MustRegister(prometheus.NewCounterVec(prometheus.CounterOpts{Name: "test_requests_total", Help: "Requests"}, []string{"code"}))
+
+			err := newInitializer(registry, WithCollectorReuse()).Init(&metrics{}, "test")
+			assert.Error(t, err)
+		})
+	})
+}
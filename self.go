@@ -0,0 +1,130 @@
+package gotoprom
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	// selfNamespace is the namespace of the metrics gotoprom reports about itself
+	selfNamespace = "gotoprom"
+	// observeSampling is the number of calls to each metric func for each one whose duration is observed
+	observeSampling = 100
+)
+
+// Kinds of the errors found after the metrics are initialized, reported in gotoprom_runtime_errors_total
+const (
+	registerErrorKind = "register"
+	resetErrorKind    = "reset"
+	deleteErrorKind   = "delete"
+	budgetErrorKind   = "budget"
+	unscopedErrorKind = "unscoped"
+)
+
+// selfMetrics are the metrics gotoprom reports about the metrics initialized by an Initializer and its clones
+type selfMetrics struct {
+	series        *prometheus.Desc
+	runtimeErrors *prometheus.CounterVec
+	labelOverflow *prometheus.CounterVec
+	observe       *prometheus.HistogramVec
+
+	mu      sync.Mutex
+	sources []*initializer
+}
+
+// newSelfMetrics creates the self metrics of the Initializer provided
+func newSelfMetrics(in *initializer) *selfMetrics {
+	return &selfMetrics{
+		series: prometheus.NewDesc(
+			prometheus.BuildFQName(selfNamespace, "", "series"),
+			"Number of series of each metric initialized by gotoprom",
+			[]string{"metric"}, nil,
+		),
+		runtimeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: selfNamespace,
+			Name:      "runtime_errors_total",
+			Help:      "Errors found by gotoprom after initializing the metrics, like failures registering lazy metrics",
+		}, []string{"metric", "kind"}),
+		labelOverflow: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: selfNamespace,
+			Name:      "label_overflow_total",
+			Help:      "Calls to the metric funcs initialized by gotoprom with new label combinations exceeding a series budget",
+		}, []string{"metric"}),
+		observe: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: selfNamespace,
+			Name:      "observe_duration_seconds",
+			Help:      "Duration of the calls to the metric funcs initialized by gotoprom, sampled once every 100 calls",
+			Buckets:   prometheus.ExponentialBuckets(1e-7, 4, 8),
+		}, []string{"metric"}),
+		sources: []*initializer{in},
+	}
+}
+
+// addSource adds an Initializer whose metrics are reported, like a clone of the original one
+func (sm *selfMetrics) addSource(in *initializer) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+
+	sm.sources = append(sm.sources, in)
+}
+
+// Describe implements prometheus.Collector
+func (sm *selfMetrics) Describe(ch chan<- *prometheus.Desc) {
+	ch <- sm.series
+	sm.runtimeErrors.Describe(ch)
+	sm.labelOverflow.Describe(ch)
+	sm.observe.Describe(ch)
+}
+
+// Collect implements prometheus.Collector
+func (sm *selfMetrics) Collect(ch chan<- prometheus.Metric) {
+	sm.mu.Lock()
+	sources := append([]*initializer(nil), sm.sources...)
+	sm.mu.Unlock()
+
+	// The same metric can be declared several times when its collector is reused, it's only reported once
+	reported := make(map[string]bool)
+	for _, source := range sources {
+		for _, declared := range source.declaredMetrics() {
+			if reported[declared.name] || declared.series == nil {
+				continue
+			}
+			count, ok := declared.series()
+			if !ok {
+				continue
+			}
+			reported[declared.name] = true
+			ch <- prometheus.MustNewConstMetric(sm.series, prometheus.GaugeValue, float64(count), declared.name)
+		}
+	}
+	sm.runtimeErrors.Collect(ch)
+	sm.labelOverflow.Collect(ch)
+	sm.observe.Collect(ch)
+}
+
+// sampler returns the observeSampler for the metric func of the metric provided, or nil if there are no self metrics
+func (sm *selfMetrics) sampler(metric string) *observeSampler {
+	if sm == nil {
+		return nil
+	}
+	return &observeSampler{observer: sm.observe.WithLabelValues(metric)}
+}
+
+// observeSampler observes the duration of one of every observeSampling calls to a metric func
+type observeSampler struct {
+	calls    uint64
+	observer prometheus.Observer
+}
+
+// start starts observing a call to the metric func, it returns the function that stops observing it,
+// or nil if the call isn't sampled
+func (s *observeSampler) start() (stop func()) {
+	if atomic.AddUint64(&s.calls, 1)%observeSampling != 1 {
+		return nil
+	}
+	t0 := time.Now()
+	return func() { s.observer.Observe(time.Since(t0).Seconds()) }
+}
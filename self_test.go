@@ -0,0 +1,134 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_SelfMetrics(t *testing.T) {
+	type labels struct {
+		Code int `label:"code"`
+	}
+	type metrics struct {
+		Requests func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+		Workers  func() prometheus.Gauge         `name:"workers" help:"Workers"`
+	}
+
+	t.Run("series", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry, WithSelfMetrics())
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		m.Requests(labels{Code: 200}).Inc()
+		m.Requests(labels{Code: 500}).Inc()
+
+		var other struct {
+			Errors func() prometheus.Counter `name:"errors_total" help:"Errors"`
+		}
+		require.NoError(t, initializer.Clone().Init(&other, "test"))
+
+		expected := `
+# HELP gotoprom_series Number of series of each metric initialized by gotoprom
+# TYPE gotoprom_series gauge
+gotoprom_series{metric="test_errors_total"} 0
+gotoprom_series{metric="test_requests_total"} 2
+gotoprom_series{metric="test_workers"} 0
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "gotoprom_series"))
+	})
+
+	t.Run("series are counted without collecting the metrics", func(t *testing.T) {
+		in := NewInitializer(prometheus.NewRegistry(), WithSelfMetrics())
+		in.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+
+		var m struct {
+			Requests       func(labels) prometheus.Counter `name:"requests_total" help:"Requests"`
+			DeleteRequests func(labels) bool               `metric:"Requests"`
+			Queues         GaugeFunc                       `name:"queues" help:"Queues"`
+		}
+		require.NoError(t, in.Init(&m, "test"))
+		calls := 0
+		m.Queues = func() float64 { calls++; return 1 }
+		m.Requests(labels{Code: 200}).Inc()
+		m.Requests(labels{Code: 500}).Inc()
+		m.DeleteRequests(labels{Code: 500})
+
+		expected := `
+# HELP gotoprom_series Number of series of each metric initialized by gotoprom
+# TYPE gotoprom_series gauge
+gotoprom_series{metric="test_queues"} 0
+gotoprom_series{metric="test_requests_total"} 1
+`
+		self := in.(*initializer).self
+		assert.NoError(t, testutil.CollectAndCompare(self, strings.NewReader(expected), "gotoprom_series"))
+		assert.Zero(t, calls, "callbacks aren't called")
+	})
+
+	t.Run("observe duration", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry, WithSelfMetrics())
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		for i := 0; i < 2*observeSampling; i++ {
+			m.Requests(labels{Code: 200}).Inc()
+		}
+
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		var observed uint64
+		for _, family := range families {
+			if family.GetName() != "gotoprom_observe_duration_seconds" {
+				continue
+			}
+			for _, metric := range family.GetMetric() {
+				if metric.GetLabel()[0].GetValue() == "test_requests_total" {
+					observed = metric.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+		assert.Equal(t, uint64(2), observed)
+	})
+
+	t.Run("runtime errors", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_requests_total", Help: "Requests"}))
+
+		initializer := NewInitializer(registry, WithSelfMetrics(), WithLazyRegistration(), WithErrorHandler(func(error) {}))
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		m.Requests(labels{Code: 200}).Inc()
+
+		expected := `
+# HELP gotoprom_runtime_errors_total Errors found by gotoprom after initializing the metrics, like failures registering lazy metrics
+# TYPE gotoprom_runtime_errors_total counter
+gotoprom_runtime_errors_total{kind="register",metric="test_requests_total"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected), "gotoprom_runtime_errors_total"))
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := NewInitializer(registry)
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(""), "gotoprom_series"))
+	})
+}
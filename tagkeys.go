@@ -0,0 +1,191 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// metricTagKeys are the tag keys accepted in all the metric fields, besides the ones accepted by their builders
+	metricTagKeys = []string{"name", "help", "builder", "unit", "lazy", "registry", "ttl", "budget"}
+	// callbackTagKeys are the tag keys accepted in callback fields
+	callbackTagKeys = []string{"name", "help", "type", "unit", "registry"}
+	// groupTagKeys are the tag keys accepted in group fields
+	groupTagKeys = []string{"namespace", "lazy", "registry"}
+	// labelTagKeys are the tag keys accepted in label fields
+	labelTagKeys = []string{"label", "default"}
+)
+
+// ErrUnknownTagKey is the error found when a field has a tag key that is not accepted, usually a misspelled one
+type ErrUnknownTagKey struct {
+	// Field is the path of the field, like Requests.Total
+	Field string
+	// Key is the unknown tag key, like bucket
+	Key string
+	// Suggestion is the accepted tag key most similar to the unknown one, if any, like buckets
+	Suggestion string
+}
+
+// Error implements error
+func (e ErrUnknownTagKey) Error() string {
+	if e.Suggestion != "" {
+		return fmt.Sprintf("field %s: unknown tag key %q, did you mean %q?", e.Field, e.Key, e.Suggestion)
+	}
+	return fmt.Sprintf("field %s: unknown tag key %q", e.Field, e.Key)
+}
+
+// Is tells whether the target is an ErrUnknownTagKey for the same field and key, empty values in the target match any
+func (e ErrUnknownTagKey) Is(target error) bool {
+	t, ok := target.(ErrUnknownTagKey)
+	return ok && (t.Field == "" || t.Field == e.Field) && (t.Key == "" || t.Key == e.Key)
+}
+
+// DeclareTagKeys adapts the Builder provided to a BuilderV2 declaring the tag keys it accepts,
+// so unknown tag keys can be detected in the fields of the metrics it builds, even if it fails to build them.
+func DeclareTagKeys(builder Builder, keys ...string) BuilderV2 {
+	adapted := adaptBuilder(builder)
+	declared := append([]string{}, keys...)
+	return func(ctx BuildContext) (BuildResult, error) {
+		result, err := adapted(ctx)
+		result.TagKeys = declared
+		return result, err
+	}
+}
+
+// checkTagKeys checks that the tag provided only has the keys allowed, if the Initializer checks them.
+// The errors found are returned in an InitError, unless the Initializer just warns about them.
+func (in *initializer) checkTagKeys(path string, tag reflect.StructTag, allowed ...[]string) error {
+	if in.options.UnknownTagKey == nil {
+		return nil
+	}
+
+	var errs initErrors
+	for _, key := range tagKeys(tag) {
+		if !containsKey(allowed, key) {
+			errs.add(in.options.UnknownTagKey(ErrUnknownTagKey{Field: path, Key: key, Suggestion: suggestKey(key, allowed)}))
+		}
+	}
+	return errs.err()
+}
+
+// checkLabelTagKeys checks the tag keys of the fields of the labels struct type provided, whose fields are in the path provided
+func (in *initializer) checkLabelTagKeys(path string, typ reflect.Type) error {
+	if in.options.UnknownTagKey == nil || typ.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs initErrors
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.Type.Kind() == reflect.Struct {
+			errs.add(in.checkTagKeys(path+"."+f.Name, f.Tag))
+			errs.add(in.checkLabelTagKeys(path+"."+f.Name, f.Type))
+		} else {
+			errs.add(in.checkTagKeys(path+"."+f.Name, f.Tag, labelTagKeys))
+		}
+	}
+	return errs.err()
+}
+
+// tagKeys returns the keys of the tag provided, which follows the conventional format of key:"value" pairs
+func tagKeys(tag reflect.StructTag) []string {
+	var keys []string
+	for tag != "" {
+		// Skip leading space
+		i := 0
+		for i < len(tag) && tag[i] == ' ' {
+			i++
+		}
+		tag = tag[i:]
+		if tag == "" {
+			break
+		}
+
+		// Scan to colon, a space or a quote is a syntax error
+		i = 0
+		for i < len(tag) && tag[i] > ' ' && tag[i] != ':' && tag[i] != '"' && tag[i] != 0x7f {
+			i++
+		}
+		if i == 0 || i+1 >= len(tag) || tag[i] != ':' || tag[i+1] != '"' {
+			break
+		}
+		keys = append(keys, string(tag[:i]))
+		tag = tag[i+1:]
+
+		// Scan quoted string to find the value
+		i = 1
+		for i < len(tag) && tag[i] != '"' {
+			if tag[i] == '\\' {
+				i++
+			}
+			i++
+		}
+		if i >= len(tag) {
+			break
+		}
+		if _, err := strconv.Unquote(string(tag[:i+1])); err != nil {
+			break
+		}
+		tag = tag[i+1:]
+	}
+	return keys
+}
+
+// containsKey tells whether any of the lists of keys provided contains the key
+func containsKey(lists [][]string, key string) bool {
+	for _, keys := range lists {
+		for _, k := range keys {
+			if k == key {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// suggestKey returns the key most similar to the key provided, if it's similar enough to be a misspelling
+func suggestKey(key string, lists [][]string) string {
+	normalized := strings.ToLower(strings.Replace(key, "-", "_", -1))
+	suggestion, best := "", 3
+	for _, keys := range lists {
+		for _, k := range keys {
+			if d := editDistance(normalized, k); d < best {
+				suggestion, best = k, d
+			}
+		}
+	}
+	return suggestion
+}
+
+// editDistance returns the Levenshtein distance between the strings provided
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
@@ -0,0 +1,150 @@
+package gotoprom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_TagKeys(t *testing.T) {
+	newInitializer := func(opts ...Option) InitializerV2 {
+		initializer := NewInitializer(prometheus.NewRegistry(), opts...)
+		initializer.MustAddBuilderV2(prometheusvanilla.HistogramType, DeclareTagKeys(prometheusvanilla.BuildHistogram, prometheusvanilla.HistogramTagKeys...))
+		initializer.MustAddBuilderV2(prometheusvanilla.SummaryType, DeclareTagKeys(prometheusvanilla.BuildSummary, prometheusvanilla.SummaryTagKeys...))
+		initializer.MustAddBuilderV2(prometheusvanilla.CounterType, DeclareTagKeys(prometheusvanilla.BuildCounter, prometheusvanilla.CounterTagKeys...))
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		return initializer
+	}
+
+	type labels struct {
+		Code string `label:"code" default:"200"`
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		var metrics struct {
+			Requests struct {
+				Duration func(labels) prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"1" unit:"seconds"`
+				Latency  func() prometheus.Summary         `name:"latency_seconds" help:"Latency" objectives:"0.5" max_age:"1m"`
+				Total    func() prometheus.Counter         `name:"total" help:"Total"`
+				Depth    GaugeFunc                         `name:"depth" help:"Depth"`
+			} `namespace:"requests"`
+		}
+		err := newInitializer(WithStrictTagKeys()).Init(&metrics, "test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("tag keys are not checked by default", func(t *testing.T) {
+		var metrics struct {
+			Latency func() prometheus.Summary `name:"latency_seconds" help:"Latency" objectives:"0.5" max-age:"1m"`
+		}
+		err := newInitializer().Init(&metrics, "test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("tag keys of builders not declaring them are not checked", func(t *testing.T) {
+		var metrics struct {
+			Gauge func() prometheus.Gauge `name:"gauge" help:"Gauge" whatever:"value"`
+		}
+		err := newInitializer(WithStrictTagKeys()).Init(&metrics, "test")
+		assert.NoError(t, err)
+	})
+
+	t.Run("warns", func(t *testing.T) {
+		var warnings []error
+		var metrics struct {
+			Latency func() prometheus.Summary `name:"latency_seconds" help:"Latency" objectives:"0.5" max-age:"1m"`
+		}
+		err := newInitializer(WithTagKeysWarning(func(err error) { warnings = append(warnings, err) })).Init(&metrics, "test")
+		require.NoError(t, err)
+		require.Len(t, warnings, 1)
+		assert.EqualError(t, warnings[0], `field Latency: unknown tag key "max-age", did you mean "max_age"?`)
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		type misspelledLabels struct {
+			Code string `label:"code" defualt:"200"`
+		}
+		type nestedMisspelledLabels struct {
+			Method string `label:"method"`
+			Inner  misspelledLabels
+		}
+
+		for _, tc := range []struct {
+			desc     string
+			metrics  interface{}
+			expected ErrUnknownTagKey
+		}{
+			{
+				desc: "misspelled builder tag key making the builder fail",
+				metrics: &struct {
+					Duration func() prometheus.Histogram `name:"duration_seconds" help:"Duration" bucket:"1"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Duration", Key: "bucket", Suggestion: "buckets"},
+			},
+			{
+				desc: "misspelled common tag key",
+				metrics: &struct {
+					Total func() prometheus.Counter `name:"total" help:"Total" unt:"seconds"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Total", Key: "unt", Suggestion: "unit"},
+			},
+			{
+				desc: "group field",
+				metrics: &struct {
+					Requests struct{} `namespace:"requests" help:"Requests"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Requests", Key: "help"},
+			},
+			{
+				desc: "label field",
+				metrics: &struct {
+					Total func(misspelledLabels) prometheus.Counter `name:"total" help:"Total"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Total.Code", Key: "defualt", Suggestion: "default"},
+			},
+			{
+				desc: "nested label field",
+				metrics: &struct {
+					Requests struct {
+						Total func(nestedMisspelledLabels) prometheus.Counter `name:"total" help:"Total"`
+					} `namespace:"requests"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Requests.Total.Inner.Code", Key: "defualt", Suggestion: "default"},
+			},
+			{
+				desc: "bundle field",
+				metrics: &struct {
+					Requests func() struct {
+						Total prometheus.Counter `name:"total" help:"Total" buckets:"1"`
+					} `name:"requests"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Requests.Total", Key: "buckets"},
+			},
+			{
+				desc: "callback field",
+				metrics: &struct {
+					Depth GaugeFunc `name:"depth" help:"Depth" type:"gauge" buckets:"1"`
+				}{},
+				expected: ErrUnknownTagKey{Field: "Depth", Key: "buckets"},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				err := newInitializer(WithStrictTagKeys()).Init(tc.metrics, "test")
+				require.Error(t, err)
+				var unknown ErrUnknownTagKey
+				require.True(t, errors.As(err, &unknown), "expected %v, got %v", tc.expected, err)
+				assert.Equal(t, tc.expected, unknown)
+			})
+		}
+	})
+}
+
+func TestTagKeys(t *testing.T) {
+	assert.Equal(t, []string{"name", "help", "buckets"}, tagKeys(`name:"duration" help:"Some \"quoted\" help"  buckets:"1,2"`))
+	assert.Equal(t, []string{"name"}, tagKeys(`name:"duration" malformed`))
+	assert.Empty(t, tagKeys(``))
+}
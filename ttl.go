@@ -0,0 +1,223 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// now returns the current time, it's replaced in the tests
+var now = time.Now
+
+// ttlFromTag returns the duration of the ttl tag of the metric in the path provided, or zero if it's not defined
+func ttlFromTag(path string, tag reflect.StructTag) (time.Duration, error) {
+	ttlString, ok := tag.Lookup("ttl")
+	if !ok {
+		return 0, nil
+	}
+
+	ttl, err := time.ParseDuration(ttlString)
+	if err != nil || ttl <= 0 {
+		return 0, fmt.Errorf("field %s: ttl tag should be a positive duration, got %q", path, ttlString)
+	}
+	return ttl, nil
+}
+
+// tracking wraps the builder provided so the series of the metrics it builds are tracked,
+// and deleted once they're idle for longer than the ttl, unless it's zero.
+// The series are swept when the metric is collected, and by the sweepers started with StartSweeper.
+// Without a ttl, the series are only tracked to count them, if the collector built can delete series.
+func (in *initializer) tracking(builder BuilderV2, ttl time.Duration) BuilderV2 {
+	return func(ctx BuildContext) (BuildResult, error) {
+		result, err := builder(ctx)
+		if err != nil {
+			return result, err
+		}
+		d, ok := result.Collector.(deleter)
+		if !ok && ttl == 0 {
+			return result, nil
+		} else if !ok {
+			return result, fmt.Errorf("ttl tag needs a collector that can delete series, got %T", result.Collector)
+		}
+
+		tracker := &seriesTracker{ttl: ttl, deleter: d, series: make(map[string]trackedSeries)}
+		if ttl > 0 {
+			in.trackersMu.Lock()
+			in.trackers = append(in.trackers, tracker)
+			in.trackersMu.Unlock()
+		}
+
+		factory := result.Factory
+		result.Factory = func(labels prometheus.Labels) interface{} {
+			tracker.touch(labels)
+			return factory(labels)
+		}
+		result.Collector = &expiringCollector{Collector: result.Collector, tracker: tracker}
+		return result, nil
+	}
+}
+
+// StartSweeper starts sweeping the series of the metrics with the ttl tag initialized by the Initializer
+// every interval provided, besides sweeping them when they're collected.
+// It returns a function that stops the sweeper and waits for it to finish.
+func (in *initializer) StartSweeper(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				in.sweep()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
+
+// sweep deletes the idle series of all the metrics with the ttl tag initialized by the Initializer
+func (in *initializer) sweep() {
+	in.trackersMu.Lock()
+	trackers := append([]*seriesTracker(nil), in.trackers...)
+	in.trackersMu.Unlock()
+
+	for _, tracker := range trackers {
+		tracker.sweep()
+	}
+}
+
+// seriesTracker tracks when the series of a metric were last used, and deletes the ones idle for longer than the ttl, if any
+type seriesTracker struct {
+	ttl     time.Duration
+	deleter deleter
+
+	mu     sync.Mutex
+	series map[string]trackedSeries
+}
+
+// trackedSeries is a series tracked by a seriesTracker
+type trackedSeries struct {
+	labels   prometheus.Labels
+	lastUsed time.Time
+}
+
+// touch records that the series with the labels provided is being used
+func (t *seriesTracker) touch(labels prometheus.Labels) {
+	key := seriesKey(labels)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.series[key] = trackedSeries{labels: labels, lastUsed: now()}
+}
+
+// forget stops tracking the series with the labels provided, or all of them if labels is nil
+func (t *seriesTracker) forget(labels prometheus.Labels) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if labels == nil {
+		t.series = make(map[string]trackedSeries)
+		return
+	}
+	delete(t.series, seriesKey(labels))
+}
+
+// count returns the number of series tracked
+func (t *seriesTracker) count() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return len(t.series)
+}
+
+// sweep deletes the series idle for longer than the ttl, if any
+func (t *seriesTracker) sweep() {
+	if t.ttl == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	expired := now().Add(-t.ttl)
+	for key, series := range t.series {
+		if series.lastUsed.Before(expired) {
+			t.deleter.Delete(series.labels)
+			delete(t.series, key)
+		}
+	}
+}
+
+// seriesKey returns a key identifying the series with the labels provided
+func seriesKey(labels prometheus.Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for name, value := range labels {
+		pairs = append(pairs, name+"\xff"+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "\xfe")
+}
+
+// expiringCollector is a prometheus.Collector that deletes the idle series of the collector it wraps before collecting them,
+// and keeps its tracker up to date when its series are deleted
+type expiringCollector struct {
+	prometheus.Collector
+	tracker *seriesTracker
+}
+
+// Collect implements prometheus.Collector
+func (c *expiringCollector) Collect(ch chan<- prometheus.Metric) {
+	c.tracker.sweep()
+	c.Collector.Collect(ch)
+}
+
+// Delete deletes the series with the labels provided, telling whether it existed
+func (c *expiringCollector) Delete(labels prometheus.Labels) bool {
+	c.tracker.forget(labels)
+	return c.tracker.deleter.Delete(labels)
+}
+
+// Reset deletes all the series, the tracked ones if the collector wrapped can't be reset
+func (c *expiringCollector) Reset() {
+	if r, ok := c.Collector.(resetter); ok {
+		r.Reset()
+		c.tracker.forget(nil)
+		return
+	}
+
+	c.tracker.mu.Lock()
+	defer c.tracker.mu.Unlock()
+	for key, series := range c.tracker.series {
+		c.tracker.deleter.Delete(series.labels)
+		delete(c.tracker.series, key)
+	}
+}
+
+// countTracked returns a function counting the series tracked for the collectors returned by the function provided,
+// which can't count them unless all the collectors track their series
+func countTracked(collectors func() []prometheus.Collector) func() (int, bool) {
+	return func() (int, bool) {
+		count := 0
+		for _, collector := range collectors() {
+			expiring, ok := collector.(*expiringCollector)
+			if !ok {
+				return 0, false
+			}
+			count += expiring.tracker.count()
+		}
+		return count, true
+	}
+}
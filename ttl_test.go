@@ -0,0 +1,128 @@
+package gotoprom
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Init_TTL(t *testing.T) {
+	clock := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	defer func(original func() time.Time) { now = original }(now)
+	now = func() time.Time { return clock }
+
+	newInitializer := func(registry *prometheus.Registry) InitializerV2 {
+		initializer := NewInitializer(registry)
+		initializer.MustAddBuilder(prometheusvanilla.GaugeType, prometheusvanilla.BuildGauge)
+		return initializer
+	}
+
+	type labels struct {
+		Pod string `label:"pod"`
+	}
+	type metrics struct {
+		Memory       func(labels) prometheus.Gauge `name:"memory_bytes" help:"Memory" ttl:"10m"`
+		DeleteMemory func(labels) bool             `metric:"Memory"`
+		ResetMemory  func()                        `metric:"Memory"`
+	}
+
+	t.Run("deletes idle series when collected", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry).Init(&m, "test"))
+
+		m.Memory(labels{Pod: "a"}).Set(1)
+		clock = clock.Add(5 * time.Minute)
+		m.Memory(labels{Pod: "b"}).Set(2)
+		clock = clock.Add(6 * time.Minute)
+
+		expected := `
+# HELP test_memory_bytes Memory
+# TYPE test_memory_bytes gauge
+test_memory_bytes{pod="b"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+
+		m.Memory(labels{Pod: "b"}).Add(1)
+		clock = clock.Add(6 * time.Minute)
+		expected = `
+# HELP test_memory_bytes Memory
+# TYPE test_memory_bytes gauge
+test_memory_bytes{pod="b"} 3
+`
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected)))
+	})
+
+	t.Run("sweeper", func(t *testing.T) {
+		initializer := newInitializer(prometheus.NewRegistry())
+		var m metrics
+		require.NoError(t, initializer.Init(&m, "test"))
+
+		m.Memory(labels{Pod: "a"}).Set(1)
+		clock = clock.Add(11 * time.Minute)
+
+		stop := initializer.StartSweeper(time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		stop()
+		stop()
+		assert.False(t, m.DeleteMemory(labels{Pod: "a"}), "swept")
+	})
+
+	t.Run("companions", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		var m metrics
+		require.NoError(t, newInitializer(registry).Init(&m, "test"))
+
+		m.Memory(labels{Pod: "a"}).Set(1)
+		m.Memory(labels{Pod: "b"}).Set(2)
+		assert.True(t, m.DeleteMemory(labels{Pod: "a"}))
+		m.ResetMemory()
+		assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader("")))
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc: "invalid duration",
+				metrics: &struct {
+					Memory func(labels) prometheus.Gauge `name:"memory_bytes" help:"Memory" ttl:"10"`
+				}{},
+			},
+			{
+				desc: "negative duration",
+				metrics: &struct {
+					Memory func(labels) prometheus.Gauge `name:"memory_bytes" help:"Memory" ttl:"-1m"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				assert.Error(t, newInitializer(prometheus.NewRegistry()).Init(tc.metrics, "test"))
+			})
+		}
+
+		t.Run("collector that can't delete series", func(t *testing.T) {
+			initializer := NewInitializer(prometheus.NewRegistry())
+			initializer.MustAddBuilderV2(prometheusvanilla.GaugeType, func(ctx BuildContext) (BuildResult, error) {
+				gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: ctx.Name, Help: ctx.Help, Namespace: ctx.Namespace})
+				return BuildResult{
+					Factory:   func(prometheus.Labels) interface{} { return gauge },
+					Collector: gauge,
+				}, nil
+			})
+
+			var m struct {
+				Memory func() prometheus.Gauge `name:"memory_bytes" help:"Memory" ttl:"10m"`
+			}
+			assert.Error(t, initializer.Init(&m, "test"))
+		})
+	})
+}
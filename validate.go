@@ -0,0 +1,78 @@
+package gotoprom
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Validate checks the metrics like Init does, calling the builders and the middlewares, and registering even the lazy metrics,
+// but in registries of its own and without setting the metric functions.
+// The metrics are also checked not to collide with the ones gathered from the gatherers provided.
+// All the errors found are returned in an InitError.
+func (in *initializer) Validate(metrics interface{}, namespace string, against ...prometheus.Gatherer) error {
+	metricsPtr := reflect.ValueOf(metrics)
+	if metricsPtr.Kind() != reflect.Ptr {
+		return InitError{Errors: []error{fmt.Errorf("expected pointer to metrics struct, got %q", metricsPtr.Kind())}}
+	}
+
+	registry := prometheus.NewRegistry()
+	for _, gatherer := range against {
+		if err := registerGathered(registry, gatherer); err != nil {
+			return InitError{Errors: []error{fmt.Errorf("gather metrics to validate against: %s", err)}}
+		}
+	}
+
+	dryRun := in.clone()
+	dryRun.eager = true
+	// Each registry is replaced by a fresh one, the default one also describing the metrics gathered
+	dryRun.registerers = make(map[string]prometheus.Registerer, len(in.registerers))
+	for name := range in.registerers {
+		var registerer prometheus.Registerer = prometheus.NewRegistry()
+		if name == DefaultRegistry {
+			registerer = registry
+		}
+		if len(in.options.ConstLabels) > 0 {
+			registerer = prometheus.WrapRegistererWith(in.options.ConstLabels, registerer)
+		}
+		dryRun.registerers[name] = registerer
+	}
+
+	_, err := dryRun.initMetrics(metricsPtr.Elem(), scope{namespaces: []string{namespace}})
+	return err
+}
+
+// registerGathered registers in the registry provided a collector describing each one of the metric families
+// gathered from the gatherer provided, so registering metrics with the same names fails
+func registerGathered(registry *prometheus.Registry, gatherer prometheus.Gatherer) error {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return err
+	}
+
+	for _, family := range families {
+		var labelNames []string
+		if len(family.GetMetric()) > 0 {
+			for _, pair := range family.GetMetric()[0].GetLabel() {
+				labelNames = append(labelNames, pair.GetName())
+			}
+		}
+		// Families with the same name gathered from several gatherers are already described
+		_ = registry.Register(gatheredCollector{desc: prometheus.NewDesc(family.GetName(), family.GetHelp(), labelNames, nil)})
+	}
+	return nil
+}
+
+// gatheredCollector is a prometheus.Collector describing a metric family that was already gathered, it collects nothing
+type gatheredCollector struct {
+	desc *prometheus.Desc
+}
+
+// Describe implements prometheus.Collector
+func (c gatheredCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+// Collect implements prometheus.Collector
+func (c gatheredCollector) Collect(chan<- prometheus.Metric) {}
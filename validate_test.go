@@ -0,0 +1,95 @@
+package gotoprom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/cabify/gotoprom/prometheusvanilla"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitializer_Validate(t *testing.T) {
+	newInitializer := func(registry *prometheus.Registry) InitializerV2 {
+		initializer := NewInitializer(registry, WithConstLabels(prometheus.Labels{"env": "test"}))
+		initializer.MustAddBuilder(prometheusvanilla.CounterType, prometheusvanilla.BuildCounter)
+		initializer.MustAddBuilder(prometheusvanilla.HistogramType, prometheusvanilla.BuildHistogram)
+		return initializer
+	}
+
+	t.Run("happy case", func(t *testing.T) {
+		registry := prometheus.NewRegistry()
+		initializer := newInitializer(registry)
+
+		var metrics struct {
+			Requests func() prometheus.Counter   `name:"requests_total" help:"Requests"`
+			Duration func() prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"1,2"`
+			Depth    GaugeFunc                   `name:"depth" help:"Depth"`
+		}
+		err := initializer.Validate(&metrics, "test")
+		require.NoError(t, err)
+
+		assert.Nil(t, metrics.Requests, "fields are not set")
+		families, err := registry.Gather()
+		require.NoError(t, err)
+		assert.Empty(t, families, "metrics are not registered")
+
+		err = initializer.Init(&metrics, "test")
+		assert.NoError(t, err, "metrics can be initialized after being validated")
+	})
+
+	t.Run("fails", func(t *testing.T) {
+		existing := prometheus.NewRegistry()
+		existing.MustRegister(prometheus.NewCounter(prometheus.CounterOpts{Name: "test_existing_total", Help: "Existing"}))
+
+		for _, tc := range []struct {
+			desc    string
+			metrics interface{}
+		}{
+			{
+				desc:    "not a pointer",
+				metrics: struct{}{},
+			},
+			{
+				desc: "tag parsed by the builder",
+				metrics: &struct {
+					Duration func() prometheus.Histogram `name:"duration_seconds" help:"Duration" buckets:"wrong"`
+				}{},
+			},
+			{
+				desc: "name collision within the struct",
+				metrics: &struct {
+					Requests      func() prometheus.Counter `name:"requests_total" help:"Requests"`
+					OtherRequests func() prometheus.Counter `name:"requests_total" help:"Other requests"`
+				}{},
+			},
+			{
+				desc: "name collision with a gathered metric",
+				metrics: &struct {
+					Existing func() prometheus.Counter `name:"existing_total" help:"Existing"`
+				}{},
+			},
+		} {
+			t.Run(tc.desc, func(t *testing.T) {
+				err := newInitializer(prometheus.NewRegistry()).Validate(tc.metrics, "test", existing)
+				assert.Error(t, err)
+				assert.True(t, errors.As(err, &InitError{}))
+			})
+		}
+	})
+
+	t.Run("fails gathering the metrics to validate against", func(t *testing.T) {
+		failing := prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+			return nil, errors.New("gathering failed")
+		})
+
+		var metrics struct {
+			Requests func() prometheus.Counter `name:"requests_total" help:"Requests"`
+		}
+		err := newInitializer(prometheus.NewRegistry()).Validate(&metrics, "test", failing)
+		assert.EqualError(t, err, "gather metrics to validate against: gathering failed")
+		assert.True(t, errors.As(err, &InitError{}))
+	})
+}